@@ -0,0 +1,303 @@
+package code
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/tifye/shigure/assert"
+)
+
+//go:embed templates/*
+var templates embed.FS
+
+const calendarDateLayout = "2006-01-02"
+
+type CalendarDay struct {
+	Date      string `json:"date"`
+	Seconds   uint   `json:"seconds"`
+	TimeSpent string `json:"timeSpent"`
+	// Level is the intensity bucket of the day
+	// from 0 (no activity) to 4.
+	Level uint `json:"level"`
+}
+
+type Streak struct {
+	Days  uint   `json:"days"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type CalendarStats struct {
+	Year           int           `json:"year"`
+	TotalTimeSpent string        `json:"totalTimeSpent"`
+	Days           []CalendarDay `json:"days"`
+	// Streaks are computed over all time
+	// and not only the calendar year.
+	CurrentStreak  Streak `json:"currentStreak"`
+	LongestStreak  Streak `json:"longestStreak"`
+	BusiestWeekday string `json:"busiestWeekday,omitempty"`
+	// Hour of the day from 0-23, or -1 if
+	// there is no activity.
+	BusiestHour int `json:"busiestHour"`
+}
+
+// CalendarStats returns a per-day breakdown of the coding time
+// for a year in the client's location. The time range of filter
+// is ignored.
+func (c *ActivityClient) CalendarStats(ctx context.Context, year int, filter StatsFilter) (CalendarStats, error) {
+	now := time.Now().In(c.location)
+	filter.From = time.Date(year, time.January, 1, 0, 0, 0, 0, c.location)
+	filter.To = filter.From.AddDate(1, 0, 0)
+
+	storedDays, err := c.store.CalendarDays(ctx, filter, c.location)
+	if err != nil {
+		return CalendarStats{}, fmt.Errorf("get stored calendar days: %s", err)
+	}
+
+	days, total := fillCalendarDays(filter.From, filter.To, storedDays)
+
+	hourly, err := c.store.HourlyActivity(ctx, filter, c.location)
+	if err != nil {
+		return CalendarStats{}, fmt.Errorf("get stored hourly activity: %s", err)
+	}
+
+	allTime := filter
+	allTime.From, allTime.To = time.Time{}, time.Time{}
+	streaks, err := c.store.Streaks(ctx, allTime, c.location)
+	if err != nil {
+		return CalendarStats{}, fmt.Errorf("get stored streaks: %s", err)
+	}
+
+	stats := CalendarStats{
+		Year:           year,
+		TotalTimeSpent: total.String(),
+		Days:           days,
+		BusiestHour:    -1,
+	}
+	stats.CurrentStreak, stats.LongestStreak = currentAndLongestStreak(streaks, now)
+	stats.BusiestWeekday, stats.BusiestHour = busiestWeekdayAndHour(hourly)
+
+	return stats, nil
+}
+
+// fillCalendarDays returns every day in [from, to) including those
+// without activity, as well as the total time spent.
+func fillCalendarDays(from, to time.Time, stored []StoredCalendarDay) ([]CalendarDay, time.Duration) {
+	secondsByDate := make(map[string]float64, len(stored))
+	for _, d := range stored {
+		secondsByDate[d.Date.Format(calendarDateLayout)] = d.Seconds
+	}
+
+	var (
+		days  []CalendarDay
+		total time.Duration
+	)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(calendarDateLayout)
+		timeSpent := time.Duration(secondsByDate[date] * float64(time.Second)).Truncate(time.Second)
+		total += timeSpent
+		days = append(days, CalendarDay{
+			Date:      date,
+			Seconds:   uint(timeSpent.Seconds()),
+			TimeSpent: timeSpent.String(),
+		})
+	}
+
+	assignCalendarLevels(days)
+	return days, total
+}
+
+// assignCalendarLevels buckets each active day into one of four
+// levels using the quartiles of all active days.
+func assignCalendarLevels(days []CalendarDay) {
+	var active []uint
+	for _, d := range days {
+		if d.Seconds > 0 {
+			active = append(active, d.Seconds)
+		}
+	}
+	if len(active) == 0 {
+		return
+	}
+	slices.Sort(active)
+
+	quartile := func(q int) uint {
+		return active[(len(active)-1)*q/4]
+	}
+	thresholds := [...]uint{quartile(1), quartile(2), quartile(3)}
+
+	for i := range days {
+		if days[i].Seconds == 0 {
+			continue
+		}
+		level := uint(1)
+		for _, t := range thresholds {
+			if days[i].Seconds > t {
+				level++
+			}
+		}
+		days[i].Level = level
+	}
+}
+
+// currentAndLongestStreak expects streaks ordered by most recent
+// first. A streak is still current if it ended yesterday.
+func currentAndLongestStreak(streaks []StoredStreak, now time.Time) (current Streak, longest Streak) {
+	if len(streaks) == 0 {
+		return Streak{}, Streak{}
+	}
+
+	toStreak := func(s StoredStreak) Streak {
+		return Streak{
+			Days:  s.Days,
+			Start: s.Start.Format(calendarDateLayout),
+			End:   s.End.Format(calendarDateLayout),
+		}
+	}
+
+	yesterday := now.AddDate(0, 0, -1).Format(calendarDateLayout)
+	if latest := toStreak(streaks[0]); latest.End >= yesterday {
+		current = latest
+	}
+
+	longestStored := slices.MaxFunc(streaks, func(a, b StoredStreak) int {
+		if a.Days != b.Days {
+			return int(a.Days) - int(b.Days)
+		}
+		return a.End.Compare(b.End)
+	})
+	return current, toStreak(longestStored)
+}
+
+func busiestWeekdayAndHour(hourly []StoredHourlyActivity) (string, int) {
+	var (
+		weekdays [7]float64
+		hours    [24]float64
+	)
+	for _, h := range hourly {
+		assert.Assert(h.Weekday >= 1 && h.Weekday <= 7, "expected ISO day of week")
+		assert.Assert(h.Hour < 24, "expected hour of day")
+		weekdays[h.Weekday-1] += h.Seconds
+		hours[h.Hour] += h.Seconds
+	}
+
+	busiestWeekday, busiestHour := -1, -1
+	for i, s := range weekdays {
+		if s > 0 && (busiestWeekday < 0 || s > weekdays[busiestWeekday]) {
+			busiestWeekday = i
+		}
+	}
+	for i, s := range hours {
+		if s > 0 && (busiestHour < 0 || s > hours[busiestHour]) {
+			busiestHour = i
+		}
+	}
+
+	if busiestWeekday < 0 {
+		return "", -1
+	}
+	// ISO weeks start on Monday while time.Weekday starts on Sunday
+	return time.Weekday((busiestWeekday + 1) % 7).String(), busiestHour
+}
+
+var calendarLevelColors = [...]string{"#161b22", "#0e4429", "#006d32", "#26a641", "#39d353"}
+
+const (
+	calendarCellSize   = 10
+	calendarCellStep   = 13
+	calendarLeftMargin = 30
+	calendarTopMargin  = 20
+)
+
+type calendarCell struct {
+	X, Y  int
+	Color string
+	Title string
+}
+
+type calendarLabel struct {
+	X, Y int
+	Text string
+}
+
+var calendarTemplate = sync.OnceValue(func() *template.Template {
+	return template.Must(template.ParseFS(templates, "templates/calendar.svg"))
+})
+
+// StreamCalendarSVG renders stats as a contribution grid with
+// one column per week, starting on Monday.
+func StreamCalendarSVG(out io.Writer, stats CalendarStats) error {
+	var (
+		cells  []calendarCell
+		months []calendarLabel
+	)
+	col := 0
+	for i, d := range stats.Days {
+		date, err := time.Parse(calendarDateLayout, d.Date)
+		assert.Assert(err == nil, "expected valid calendar date")
+
+		row := (int(date.Weekday()) + 6) % 7
+		if i > 0 && row == 0 {
+			col++
+		}
+		x := calendarLeftMargin + col*calendarCellStep
+		if date.Day() == 1 {
+			months = append(months, calendarLabel{X: x, Y: calendarTopMargin - 6, Text: date.Month().String()[:3]})
+		}
+
+		cells = append(cells, calendarCell{
+			X:     x,
+			Y:     calendarTopMargin + row*calendarCellStep,
+			Color: calendarLevelColors[d.Level],
+			Title: fmt.Sprintf("%s: %s", d.Date, d.TimeSpent),
+		})
+	}
+
+	weekdays := []calendarLabel{
+		{X: 0, Y: calendarTopMargin + 0*calendarCellStep + calendarCellSize, Text: "Mon"},
+		{X: 0, Y: calendarTopMargin + 2*calendarCellStep + calendarCellSize, Text: "Wed"},
+		{X: 0, Y: calendarTopMargin + 4*calendarCellStep + calendarCellSize, Text: "Fri"},
+	}
+
+	legend := make([]calendarCell, len(calendarLevelColors))
+	legendY := calendarTopMargin + 7*calendarCellStep + 6
+	for i, color := range calendarLevelColors {
+		legend[i] = calendarCell{
+			X:     calendarLeftMargin + (col-len(calendarLevelColors)+1+i)*calendarCellStep,
+			Y:     legendY,
+			Color: color,
+		}
+	}
+
+	input := struct {
+		Width, Height int
+		CellSize      int
+		Cells         []calendarCell
+		Months        []calendarLabel
+		Weekdays      []calendarLabel
+		Legend        []calendarCell
+		Summary       calendarLabel
+	}{
+		Width:    calendarLeftMargin + (col+1)*calendarCellStep,
+		Height:   legendY + calendarCellStep + 4,
+		CellSize: calendarCellSize,
+		Cells:    cells,
+		Months:   months,
+		Weekdays: weekdays,
+		Legend:   legend,
+		Summary: calendarLabel{
+			X: calendarLeftMargin,
+			Y: legendY + calendarCellSize,
+			Text: fmt.Sprintf("%s in %d · current streak %d days · longest %d days",
+				stats.TotalTimeSpent, stats.Year, stats.CurrentStreak.Days, stats.LongestStreak.Days),
+		},
+	}
+
+	return calendarTemplate().ExecuteTemplate(out, "calendar.svg", input)
+}
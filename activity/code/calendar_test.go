@@ -0,0 +1,70 @@
+package code
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarStats(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	client := &ActivityClient{store: store, location: loc}
+
+	insert := func(at time.Time) {
+		require.NoError(t, store.Insert(ctx, CodeActivity{Repository: "shigure", ReportedAt: at}))
+	}
+	// 23:00 UTC on the 2nd is the 3rd locally
+	for _, day := range []int{2, 3, 4, 10} {
+		start := time.Date(2024, time.March, day, 23, 0, 0, 0, time.UTC)
+		insert(start)
+		insert(start.Add(10 * time.Minute))
+		insert(start.Add(20 * time.Minute))
+	}
+
+	stats, err := client.CalendarStats(ctx, 2024, StatsFilter{})
+	require.NoError(t, err)
+
+	assert.Len(t, stats.Days, 366)
+	assert.Equal(t, "1h20m0s", stats.TotalTimeSpent)
+	for _, d := range stats.Days {
+		switch d.Date {
+		case "2024-03-03", "2024-03-04", "2024-03-05", "2024-03-11":
+			assert.Equal(t, uint(20*60), d.Seconds, d.Date)
+			assert.NotZero(t, d.Level, d.Date)
+		default:
+			assert.Zero(t, d.Seconds, d.Date)
+			assert.Zero(t, d.Level, d.Date)
+		}
+	}
+
+	assert.Equal(t, Streak{Days: 3, Start: "2024-03-03", End: "2024-03-05"}, stats.LongestStreak)
+	assert.Zero(t, stats.CurrentStreak.Days)
+	assert.Equal(t, 8, stats.BusiestHour)
+
+	var buf bytes.Buffer
+	require.NoError(t, StreamCalendarSVG(&buf, stats))
+	assert.Contains(t, buf.String(), "2024-03-11: 20m0s")
+}
+
+func TestCurrentStreakEndingYesterday(t *testing.T) {
+	now := time.Date(2025, time.May, 10, 12, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2025, time.May, d, 0, 0, 0, 0, time.UTC) }
+	streaks := []StoredStreak{
+		{Start: day(8), End: day(9), Days: 2},
+		{Start: day(1), End: day(5), Days: 5},
+	}
+
+	current, longest := currentAndLongestStreak(streaks, now)
+	assert.Equal(t, uint(2), current.Days)
+	assert.Equal(t, uint(5), longest.Days)
+
+	current, _ = currentAndLongestStreak(streaks, now.AddDate(0, 0, 2))
+	assert.Zero(t, current.Days)
+}
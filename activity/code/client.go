@@ -5,8 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
-	"path"
-	"strings"
+	"regexp"
+	"slices"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,21 +17,12 @@ import (
 	"github.com/tifye/shigure/mux"
 )
 
-type VSCodeActivity struct {
-	RepositoryURL string `json:"repository,omitempty"`
-	Workspace     string `json:"workspace"`
-	Filename      string `json:"fileName"`
-	Language      string `json:"language"`
-	Row           uint   `json:"row"`
-	Col           uint   `json:"col"`
-	CodeChunk     string `json:"viewChunk"`
-}
-
-var defaultAcitivty = VSCodeActivity{
+var defaultAcitivty = EditorActivity{
+	Editor:        EditorVSCode,
 	RepositoryURL: "https://github.com/tifye",
 	Workspace:     "Unknown",
 	Filename:      "inactive.md",
-	Language:      "Probably english",
+	Language:      "Markdown",
 	Row:           1,
 	Col:           3,
 	CodeChunk: `
@@ -48,27 +40,82 @@ var defaultAcitivty = VSCodeActivity{
 
 type ActivityClient struct {
 	logger     *log.Logger
-	activity   VSCodeActivity
+	activity   EditorActivity
 	lastUpdate atomic.Value
 	mu         sync.RWMutex
-
-	store         *CodeActivityStore
-	redactedRepos *redactedRepos
+	// version is bumped every time activity
+	// changes, guarded by mu.
+	version    uint64
+	modifiedAt time.Time
+	// presence of the owner, guarded by mu.
+	presence Presence
+
+	presenceThresholds PresenceThresholds
+	placeholder        EditorActivity
+
+	cardCache map[string]cachedCard
+	cardMu    sync.Mutex
+
+	store *CodeActivityStore
+	// redaction holds the rules of each user, loaded
+	// on first use and guarded by redactionMu.
+	redaction   map[string]*redactionRules
+	redactionMu sync.Mutex
+	aliases     *repositoryAliases
 
 	mux            *mux.Mux
 	muxMessageType string
+	// Activity is also broadcast on the legacy
+	// message type for older subscribers.
+	legacyMuxMessageType string
+
+	location *time.Location
+
+	autoRedactSecretFiles bool
+}
+
+type ActivityClientOptions struct {
+	// Location used to compute day boundaries of
+	// stats range presets. Defaults to UTC.
+	Location *time.Location
+	// AutoRedactSecretFiles adds a redaction rule hiding
+	// the code chunk of every file of the same type as
+	// a file in which secrets were found.
+	AutoRedactSecretFiles bool
+	// Presence thresholds, defaults to DefaultPresenceThresholds.
+	Presence PresenceThresholds
+	// Placeholder is the activity shown while the owner
+	// is away, defaults to a friendly code chunk.
+	Placeholder *EditorActivity
 }
 
 func NewActivityClient(
 	logger *log.Logger,
 	mux *mux.Mux,
 	store *CodeActivityStore,
+	opts ActivityClientOptions,
 ) *ActivityClient {
 	assert.AssertNotNil(logger)
 	assert.AssertNotNil(mux)
 	assert.AssertNotNil(store)
 
-	rr, err := newRedactedRepos("./data/redactedRepos")
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.Presence == (PresenceThresholds{}) {
+		opts.Presence = DefaultPresenceThresholds
+	}
+	assert.Assert(opts.Presence.Validate() == nil, "expected valid presence thresholds")
+	placeholder := defaultAcitivty
+	if opts.Placeholder != nil {
+		placeholder = *opts.Placeholder
+	}
+
+	rr, err := newRedactionRules(DefaultRedactionRulesPath, "./data/redactedRepos")
+	if err != nil {
+		panic(err)
+	}
+	aliases, err := newRepositoryAliases(DefaultRepositoryAliasesPath)
 	if err != nil {
 		panic(err)
 	}
@@ -76,141 +123,400 @@ func NewActivityClient(
 	ac := &ActivityClient{
 		logger:         logger,
 		mux:            mux,
-		muxMessageType: "vscode",
-		activity:       defaultAcitivty,
+		muxMessageType: "editor",
+		activity:       placeholder,
 		store:          store,
-		redactedRepos:  rr,
+		redaction:      map[string]*redactionRules{OwnerUser: rr},
+		aliases:        aliases,
 		lastUpdate:     atomic.Value{},
+		location:       opts.Location,
+		cardCache:      map[string]cachedCard{},
+
+		autoRedactSecretFiles: opts.AutoRedactSecretFiles,
+		legacyMuxMessageType:  "vscode",
+		presence:              PresenceOffline,
+		presenceThresholds:    opts.Presence,
+		placeholder:           placeholder,
 	}
-	ac.lastUpdate.Store(time.Now())
-
-	ticker := time.NewTicker(5 * time.Minute)
-	// intentionally run for lifetime
-	go func() {
-		for range ticker.C {
-			if time.Since(ac.lastUpdate.Load().(time.Time)) >= 15*time.Minute {
-				ac.mu.Lock()
-				ac.activity = defaultAcitivty
-				ac.mu.Unlock()
-			}
-		}
-	}()
+	ac.lastUpdate.Store(time.Time{})
 
 	return ac
 }
 
-func (c *ActivityClient) MarkRepoRedacted(repo string) error {
-	return c.redactedRepos.redactRepo(repo)
+// Run keeps the owner's presence up to date until ctx is done.
+func (c *ActivityClient) Run(ctx context.Context) {
+	ticker := time.NewTicker(presenceCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.checkPresence(now)
+		}
+	}
+}
+
+// checkPresence broadcasts the owner's presence when it changed,
+// replacing the current activity with the placeholder once away.
+func (c *ActivityClient) checkPresence(now time.Time) {
+	presence := c.presenceThresholds.presence(now.Sub(c.lastUpdate.Load().(time.Time)))
+
+	c.mu.Lock()
+	if presence == c.presence {
+		c.mu.Unlock()
+		return
+	}
+	c.presence = presence
+	if presence.showsPlaceholder() && c.activity != c.placeholder {
+		c.activity = c.placeholder
+		c.version++
+		c.modifiedAt = now
+	}
+	a := c.activity
+	c.mu.Unlock()
+
+	c.logger.Debug("presence changed", "presence", presence)
+	c.broadcast(a, presence)
+}
+
+// MarkRepoRedacted hides the code chunk of all of the
+// owner's activity in exactly the repository repo.
+func (c *ActivityClient) MarkRepoRedacted(ctx context.Context, repo string) error {
+	_, err := c.PutRedactionRule(ctx, OwnerUser, RedactionRule{
+		Repository: regexp.QuoteMeta(repo),
+		Regex:      true,
+		Actions:    []RedactionAction{RedactHideChunk},
+	})
+	return err
+}
+
+// CanonicalRepository returns the canonical identity of the
+// repository remote repo, following aliases if any.
+func (c *ActivityClient) CanonicalRepository(repo string) string {
+	return c.aliases.resolve(NormalizeRepository(repo))
+}
+
+func (c *ActivityClient) RepositoryAliases() []RepositoryAlias {
+	return c.aliases.list()
+}
+
+// PutRepositoryAlias adds, or replaces, an alias and merges the
+// stored activity of all users in alias.From into alias.To.
+func (c *ActivityClient) PutRepositoryAlias(ctx context.Context, alias RepositoryAlias) (RepositoryAlias, error) {
+	alias.From = NormalizeRepository(alias.From)
+	alias.To = NormalizeRepository(alias.To)
+	if err := c.aliases.put(alias); err != nil {
+		return RepositoryAlias{}, err
+	}
+
+	affected, err := c.store.RenameRepository(ctx, alias.From, c.aliases.resolve(alias.To))
+	if err != nil {
+		return alias, fmt.Errorf("rename stored repository: %s", err)
+	}
+	c.logger.Info("applied repository alias", "from", alias.From, "to", alias.To, "affected", affected)
+
+	return alias, nil
+}
+
+// RemoveRepositoryAlias stops merging activity of the repository
+// from. Activity that was already merged is not split up again.
+func (c *ActivityClient) RemoveRepositoryAlias(from string) error {
+	return c.aliases.remove(NormalizeRepository(from))
+}
+
+// redactionRules returns the rules of user, loading
+// them if needed.
+func (c *ActivityClient) redactionRules(user string) (*redactionRules, error) {
+	if !ValidUser(user) {
+		return nil, ErrInvalidUser
+	}
+
+	c.redactionMu.Lock()
+	defer c.redactionMu.Unlock()
+
+	if rr, ok := c.redaction[user]; ok {
+		return rr, nil
+	}
+
+	rr, err := newRedactionRules(RedactionRulesPath(user), "")
+	if err != nil {
+		return nil, fmt.Errorf("load redaction rules of %s: %s", user, err)
+	}
+	c.redaction[user] = rr
+	return rr, nil
+}
+
+func (c *ActivityClient) RedactionRules(user string) ([]RedactionRule, error) {
+	rr, err := c.redactionRules(user)
+	if err != nil {
+		return nil, err
+	}
+	return rr.list(), nil
+}
+
+// PutRedactionRule adds a new rule of user, or replaces an existing
+// one if rule.ID is set, and retroactively applies it to all of
+// the user's stored activity. Retroactive changes are permanent and
+// are not undone when the rule is removed.
+func (c *ActivityClient) PutRedactionRule(ctx context.Context, user string, rule RedactionRule) (RedactionRule, error) {
+	rr, err := c.redactionRules(user)
+	if err != nil {
+		return RedactionRule{}, err
+	}
+
+	replace := rule.ID != ""
+	if !replace {
+		rule.ID = newID()
+	}
+
+	// Stored activity is redacted before the rule is kept, so that
+	// a rule that failed to apply is not left half applied
+	return rr.put(rule, replace, func(rule RedactionRule) error {
+		affected, err := c.store.ApplyRedactionRule(ctx, user, rule)
+		if err != nil {
+			return fmt.Errorf("apply rule to stored activity: %s", err)
+		}
+		c.logger.Info("applied redaction rule", "user", user, "id", rule.ID, "affected", affected)
+		return nil
+	})
+}
+
+// redactSecretFileType adds a rule of user hiding the code chunk
+// of all files of the same type as filename, unless one exists.
+// Unlike rules put by the user, it only applies to activity from
+// now on, as secrets may be false positives and stored activity
+// can't be restored.
+func (c *ActivityClient) redactSecretFileType(user string, rr *redactionRules, filename string) {
+	rule := secretFileRule(filename)
+	for _, r := range rr.list() {
+		if !r.Regex && r.Filename == rule.Filename && slices.Contains(r.Actions, RedactHideChunk) {
+			return
+		}
+	}
+
+	rule.ID = newID()
+	rule, err := rr.put(rule, false, func(RedactionRule) error { return nil })
+	if err != nil {
+		c.logger.Error("auto-redact secret file type", "err", err, "user", user, "filename", filename)
+		return
+	}
+	c.logger.Info("auto-redacted secret file type", "user", user, "pattern", rule.Filename, "id", rule.ID)
+}
+
+func (c *ActivityClient) RemoveRedactionRule(user string, id string) error {
+	rr, err := c.redactionRules(user)
+	if err != nil {
+		return err
+	}
+	return rr.remove(id)
+}
+
+// Location is the location in which stats
+// range presets are computed.
+func (c *ActivityClient) Location() *time.Location {
+	return c.location
+}
+
+// RangePreset returns the time range of the
+// named preset in the client's location.
+func (c *ActivityClient) RangePreset(preset string) (from, to time.Time, err error) {
+	return RangePreset(preset, time.Now().In(c.location))
 }
 
 func (c *ActivityClient) MessageType() string {
 	return c.muxMessageType
 }
 
+// LegacyMessageType is the message type activity was
+// broadcast on before other editors were supported.
+func (c *ActivityClient) LegacyMessageType() string {
+	return c.legacyMuxMessageType
+}
+
 func (c *ActivityClient) HandleMessage(_ *mux.Channel, _ []byte) error {
 	return nil
 }
 
-func (c *ActivityClient) SetActivity(ctx context.Context, a VSCodeActivity) {
-	c.logger.Debug("updating code activity", "repository", a.RepositoryURL)
+// SetActivity records an editor heartbeat of user. Only the owner's
+// heartbeats become the current activity, those of other users are
+// only stored. Heartbeats older than the current activity, such as
+// those queued by an editor while offline, are only stored as well.
+func (c *ActivityClient) SetActivity(ctx context.Context, user string, a EditorActivity) error {
+	c.logger.Debug("updating code activity", "user", user, "editor", a.Editor, "repository", a.RepositoryURL)
 
-	if c.redactedRepos.isRedacted(a.RepositoryURL) {
-		a.CodeChunk = strings.Repeat("[REDACTED]\n", 10)
+	rr, err := c.redactionRules(user)
+	if err != nil {
+		return err
 	}
 
-	if a.Filename != "" {
-		a.Filename = path.Base(strings.ReplaceAll(a.Filename, "\\", "/"))
-		if a.Filename == "." || a.Filename == "/" || a.Filename == `\` {
-			a.Filename = ""
+	a, redaction, numSecrets := sanitizeActivity(a, rr, c.aliases, time.Now())
+	reportedAt := a.Time
+
+	if numSecrets > 0 {
+		c.logger.Warn("masked secrets in code chunk", "count", numSecrets, "user", user, "filename", a.Filename)
+		if c.autoRedactSecretFiles {
+			c.redactSecretFileType(user, rr, a.Filename)
+			a.CodeChunk = redactedCodeChunk
 		}
 	}
-	parts := strings.FieldsFunc(a.Filename, func(r rune) bool {
-		return r == '\\' || r == '/'
-	})
-	if len(a.Filename) > 0 {
-		a.Filename = parts[len(parts)-1]
+
+	isLatest := user == OwnerUser && !reportedAt.Before(c.lastUpdate.Load().(time.Time))
+	var presence Presence
+	if isLatest {
+		c.lastUpdate.Store(reportedAt)
+
+		// Heartbeats sent long after they were recorded don't
+		// bring the owner back from being away
+		presence = c.presenceThresholds.presence(time.Since(reportedAt))
+		isLatest = !presence.showsPlaceholder()
+	}
+	if isLatest {
+		c.mu.Lock()
+		c.activity = a
+		c.presence = presence
+		c.version++
+		c.modifiedAt = time.Now()
+		c.mu.Unlock()
 	}
 
-	c.mu.Lock()
-	c.activity = a
-	c.mu.Unlock()
+	if !redaction.has(RedactDontStore) {
+		err := c.store.Insert(ctx, a.codeActivity(user, redaction))
+		if err != nil {
+			c.logger.Error("insert code activity", "err", err)
+		}
+	}
 
-	c.lastUpdate.Store(time.Now())
-
-	err := c.store.Insert(ctx, CodeActivity{
-		Repository: a.RepositoryURL,
-		Workspace:  a.Workspace,
-		Filename:   a.Filename,
-		Language:   a.Language,
-		Row:        a.Row,
-		Col:        a.Col,
-		CodeChunk:  a.CodeChunk,
-		ReportedAt: time.Now(),
-	})
-	if err != nil {
-		c.logger.Error("insert code activity", "err", err)
+	if isLatest {
+		c.broadcast(a, presence)
 	}
+	return nil
+}
 
-	msgb, err := json.Marshal(a)
+// broadcast sends the activity, along with the owner's presence,
+// on both the current and the legacy message type.
+func (c *ActivityClient) broadcast(a EditorActivity, presence Presence) {
+	msg, err := json.Marshal(activityMessage{EditorActivity: a, Presence: presence})
 	if err != nil {
-		c.logger.Error("marshal vscode activity", "err", err, "activity", a)
+		c.logger.Error("marshal editor activity", "err", err, "activity", a)
 		return
 	}
-	c.mux.Broadcast(c.muxMessageType, msgb, nil)
+	for _, typ := range []string{c.muxMessageType, c.legacyMuxMessageType} {
+		if err := c.mux.Broadcast(typ, msg, nil); err != nil {
+			c.logger.Error("broadcast activity", "err", err, "type", typ)
+		}
+	}
 }
 
-func (c *ActivityClient) Activity() VSCodeActivity {
+func (c *ActivityClient) Activity() EditorActivity {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.activity
 }
 
+// Version identifies the current activity and when it last
+// changed, for use as HTTP cache validators.
+func (c *ActivityClient) Version() (string, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return strconv.FormatUint(c.version, 10), c.modifiedAt
+}
+
+func (c *ActivityClient) Presence() Presence {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.presence
+}
+
 type Stats struct {
+	From            time.Time        `json:"from,omitzero"`
+	To              time.Time        `json:"to,omitzero"`
 	TotalTimeSpent  string           `json:"totalTimeSpent"`
 	LatestSessions  []SessionStat    `json:"latestSessions"`
 	LanguageStats   []LanguageStat   `json:"languages"`
 	RepositoryStats []RepositoryStat `json:"repositories"`
+	EditorStats     []EditorStat     `json:"editors"`
 }
 
-func (c *ActivityClient) CodeStats(ctx context.Context) (Stats, error) {
-	totalTimeSpent, err := c.totalTimeSpent(ctx)
+func (c *ActivityClient) CodeStats(ctx context.Context, filter StatsFilter) (Stats, error) {
+	totalTimeSpent, err := c.totalTimeSpent(ctx, filter)
 	if err != nil {
 		return Stats{}, fmt.Errorf("total time spent: %s", err)
 	}
 
-	languageStats, err := c.languageStats(ctx, totalTimeSpent)
+	languageStats, err := c.languageStats(ctx, filter)
 	if err != nil {
 		return Stats{}, fmt.Errorf("language stats: %s", err)
 	}
 
-	repositoryStats, err := c.repositoryStats(ctx, totalTimeSpent)
+	repositoryStats, err := c.repositoryStats(ctx, filter)
 	if err != nil {
 		return Stats{}, fmt.Errorf("repository stats: %s", err)
 	}
 
-	sessionStats, err := c.sessionStats(ctx)
+	editorStats, err := c.editorStats(ctx, filter)
+	if err != nil {
+		return Stats{}, fmt.Errorf("editor stats: %s", err)
+	}
+
+	sessionStats, err := c.sessionStats(ctx, filter)
 	if err != nil {
 		return Stats{}, fmt.Errorf("session stats: %s", err)
 	}
 
 	return Stats{
+		From:            filter.From,
+		To:              filter.To,
 		TotalTimeSpent:  totalTimeSpent.String(),
 		LatestSessions:  sessionStats,
 		LanguageStats:   languageStats,
 		RepositoryStats: repositoryStats,
+		EditorStats:     editorStats,
 	}, nil
 }
 
+type LeaderboardEntry struct {
+	Rank         int       `json:"rank"`
+	User         string    `json:"user"`
+	TimeSpent    string    `json:"timeSpent"`
+	Seconds      uint      `json:"seconds"`
+	TopLanguage  string    `json:"topLanguage,omitempty"`
+	LastReported time.Time `json:"lastReported"`
+}
+
+// Leaderboard ranks users by time spent within the time range
+// and other constraints of filter.
+func (c *ActivityClient) Leaderboard(ctx context.Context, filter StatsFilter) ([]LeaderboardEntry, error) {
+	stored, err := c.store.Leaderboard(ctx, filter, 50)
+	if err != nil {
+		return nil, fmt.Errorf("get stored leaderboard: %s", err)
+	}
+
+	entries := make([]LeaderboardEntry, len(stored))
+	for i, e := range stored {
+		timeSpent := time.Duration(e.Seconds * float64(time.Second)).Truncate(time.Second)
+		entries[i] = LeaderboardEntry{
+			Rank:         i + 1,
+			User:         e.User,
+			TimeSpent:    timeSpent.String(),
+			Seconds:      uint(timeSpent.Seconds()),
+			TopLanguage:  e.TopLanguage,
+			LastReported: e.LastReported,
+		}
+	}
+
+	return entries, nil
+}
+
 type RepositoryStat struct {
 	Repository string  `json:"repository"`
 	Percentage float64 `json:"percentage"`
 	TimeSpent  string  `json:"timeSpent"`
 }
 
-func (c *ActivityClient) repositoryStats(ctx context.Context, totalTimeSpent time.Duration) ([]RepositoryStat, error) {
-	assert.Assert(totalTimeSpent >= 0, "invalid total time spent")
-
-	reports, err := c.store.RepositoryReports(ctx)
+func (c *ActivityClient) repositoryStats(ctx context.Context, filter StatsFilter) ([]RepositoryStat, error) {
+	reports, err := c.store.RepositoryReports(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("get stored repository reports: %s", err)
 	}
@@ -221,7 +527,7 @@ func (c *ActivityClient) repositoryStats(ctx context.Context, totalTimeSpent tim
 
 	stats := make([]RepositoryStat, len(reports))
 	for i, report := range reports {
-		timeSpent := time.Duration((report.OverallPercent / 100) * float64(totalTimeSpent))
+		timeSpent := time.Duration(report.Seconds * float64(time.Second))
 		stats[i] = RepositoryStat{
 			Repository: report.Repository,
 			Percentage: math.Floor(report.OverallPercent*100) / 100,
@@ -234,14 +540,13 @@ func (c *ActivityClient) repositoryStats(ctx context.Context, totalTimeSpent tim
 
 type LanguageStat struct {
 	Language   string  `json:"language"`
+	Color      string  `json:"color"`
 	Percentage float64 `json:"percentage"`
 	TimeSpent  string  `json:"timeSpent"`
 }
 
-func (c *ActivityClient) languageStats(ctx context.Context, totalTimeSpent time.Duration) ([]LanguageStat, error) {
-	assert.Assert(totalTimeSpent >= 0, "invalid total time spent")
-
-	reports, err := c.store.LanguagesReports(ctx)
+func (c *ActivityClient) languageStats(ctx context.Context, filter StatsFilter) ([]LanguageStat, error) {
+	reports, err := c.store.LanguagesReports(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("get stored language reports: %s", err)
 	}
@@ -252,9 +557,39 @@ func (c *ActivityClient) languageStats(ctx context.Context, totalTimeSpent time.
 
 	stats := make([]LanguageStat, len(reports))
 	for i, report := range reports {
-		timeSpent := time.Duration((report.OverallPercent / 100) * float64(totalTimeSpent))
+		timeSpent := time.Duration(report.Seconds * float64(time.Second))
 		stats[i] = LanguageStat{
 			Language:   report.Language,
+			Color:      LanguageColor(report.Language),
+			Percentage: math.Floor(report.OverallPercent*100) / 100,
+			TimeSpent:  timeSpent.Truncate(time.Second).String(),
+		}
+	}
+
+	return stats, nil
+}
+
+type EditorStat struct {
+	Editor     string  `json:"editor"`
+	Percentage float64 `json:"percentage"`
+	TimeSpent  string  `json:"timeSpent"`
+}
+
+func (c *ActivityClient) editorStats(ctx context.Context, filter StatsFilter) ([]EditorStat, error) {
+	reports, err := c.store.EditorsReports(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("get stored editor reports: %s", err)
+	}
+
+	if len(reports) == 0 {
+		return nil, nil
+	}
+
+	stats := make([]EditorStat, len(reports))
+	for i, report := range reports {
+		timeSpent := time.Duration(report.Seconds * float64(time.Second))
+		stats[i] = EditorStat{
+			Editor:     report.Editor,
 			Percentage: math.Floor(report.OverallPercent*100) / 100,
 			TimeSpent:  timeSpent.Truncate(time.Second).String(),
 		}
@@ -270,8 +605,8 @@ type SessionStat struct {
 	TopRepositories []string  `json:"repositories"`
 }
 
-func (c *ActivityClient) sessionStats(ctx context.Context) ([]SessionStat, error) {
-	sessions, err := c.store.Sessions(ctx, 5)
+func (c *ActivityClient) sessionStats(ctx context.Context, filter StatsFilter) ([]SessionStat, error) {
+	sessions, err := c.store.Sessions(ctx, filter, 5)
 	if err != nil {
 		return nil, fmt.Errorf("get stored sessions: %s", err)
 	}
@@ -282,32 +617,36 @@ func (c *ActivityClient) sessionStats(ctx context.Context) ([]SessionStat, error
 
 	stats := make([]SessionStat, len(sessions))
 	for i, session := range sessions {
-		repositories := make([]string, len(session.TopRepositories))
-		for j, repo := range session.TopRepositories {
-			switch v := repo.(type) {
-			case string:
-				repositories[j] = v
-			case []byte:
-				repositories[j] = string(v)
-			default:
-				repositories[j] = fmt.Sprint(v)
-			}
-		}
-		stats[i] = SessionStat{
-			Start: session.Start,
-			End:   session.End,
-			Duration: session.End.Sub(session.Start).
-				Truncate(time.Second).
-				String(),
-			TopRepositories: repositories,
-		}
+		stats[i] = newSessionStat(session)
 	}
 
 	return stats, nil
 }
 
-func (c *ActivityClient) totalTimeSpent(ctx context.Context) (time.Duration, error) {
-	ts, err := c.store.TotatTimeSpent(ctx)
+func newSessionStat(session StoredSession) SessionStat {
+	repositories := make([]string, len(session.TopRepositories))
+	for j, repo := range session.TopRepositories {
+		switch v := repo.(type) {
+		case string:
+			repositories[j] = v
+		case []byte:
+			repositories[j] = string(v)
+		default:
+			repositories[j] = fmt.Sprint(v)
+		}
+	}
+	return SessionStat{
+		Start: session.Start,
+		End:   session.End,
+		Duration: session.End.Sub(session.Start).
+			Truncate(time.Second).
+			String(),
+		TopRepositories: repositories,
+	}
+}
+
+func (c *ActivityClient) totalTimeSpent(ctx context.Context, filter StatsFilter) (time.Duration, error) {
+	ts, err := c.store.TotatTimeSpent(ctx, filter)
 	if err != nil {
 		return 0, fmt.Errorf("get total hours: %w", err)
 	}
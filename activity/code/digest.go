@@ -0,0 +1,114 @@
+package code
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type DigestPeriod string
+
+const (
+	DigestDaily  DigestPeriod = "daily"
+	DigestWeekly DigestPeriod = "weekly"
+
+	digestTopN = 3
+)
+
+// Digest summarizes the owner's coding over the last completed
+// day or week, compared to the one before.
+type Digest struct {
+	Period            DigestPeriod     `json:"period"`
+	From              time.Time        `json:"from"`
+	To                time.Time        `json:"to"`
+	TimeSpent         time.Duration    `json:"timeSpent"`
+	PreviousTimeSpent time.Duration    `json:"previousTimeSpent"`
+	TopRepositories   []RepositoryStat `json:"repositories"`
+	TopLanguages      []LanguageStat   `json:"languages"`
+	LongestSession    *SessionStat     `json:"longestSession,omitempty"`
+}
+
+// Change is the relative change in time spent compared to the
+// previous period, or false if there was no previous activity.
+func (d Digest) Change() (float64, bool) {
+	if d.PreviousTimeSpent == 0 {
+		return 0, false
+	}
+	return float64(d.TimeSpent-d.PreviousTimeSpent) / float64(d.PreviousTimeSpent) * 100, true
+}
+
+// days is the length of the period in days.
+func (p DigestPeriod) days() (int, error) {
+	switch p {
+	case DigestDaily:
+		return 1, nil
+	case DigestWeekly:
+		return 7, nil
+	default:
+		return 0, fmt.Errorf("unknown digest period %q", p)
+	}
+}
+
+// Digest summarizes the last completed period before now in the
+// client's location.
+func (c *ActivityClient) Digest(ctx context.Context, period DigestPeriod, now time.Time) (Digest, error) {
+	days, err := period.days()
+	if err != nil {
+		return Digest{}, err
+	}
+
+	preset := RangeToday
+	if period == DigestWeekly {
+		preset = RangeThisWeek
+	}
+	current, _, err := RangePreset(preset, now.In(c.location))
+	if err != nil {
+		return Digest{}, err
+	}
+
+	from, to := current.AddDate(0, 0, -days), current
+	filter := StatsFilter{User: OwnerUser, From: from, To: to}
+	previous := filter
+	previous.From, previous.To = from.AddDate(0, 0, -days), from
+
+	timeSpent, err := c.totalTimeSpent(ctx, filter)
+	if err != nil {
+		return Digest{}, fmt.Errorf("total time spent: %s", err)
+	}
+
+	previousTimeSpent, err := c.totalTimeSpent(ctx, previous)
+	if err != nil {
+		return Digest{}, fmt.Errorf("previous total time spent: %s", err)
+	}
+
+	repositories, err := c.repositoryStats(ctx, filter)
+	if err != nil {
+		return Digest{}, fmt.Errorf("repository stats: %s", err)
+	}
+
+	languages, err := c.languageStats(ctx, filter)
+	if err != nil {
+		return Digest{}, fmt.Errorf("language stats: %s", err)
+	}
+
+	digest := Digest{
+		Period:            period,
+		From:              from,
+		To:                to,
+		TimeSpent:         timeSpent,
+		PreviousTimeSpent: previousTimeSpent,
+		TopRepositories:   repositories[:min(len(repositories), digestTopN)],
+		TopLanguages:      languages[:min(len(languages), digestTopN)],
+	}
+
+	session, ok, err := c.store.LongestSession(ctx, filter)
+	if err != nil {
+		return Digest{}, fmt.Errorf("get stored longest session: %s", err)
+	}
+	if ok {
+		stat := newSessionStat(session)
+		digest.LongestSession = &stat
+	}
+
+	return digest, nil
+}
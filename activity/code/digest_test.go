@@ -0,0 +1,52 @@
+package code
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigest(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	insert := func(start time.Time, minutes int, repo string) {
+		for i := range minutes/5 + 1 {
+			require.NoError(t, client.store.Insert(ctx, CodeActivity{
+				User:       OwnerUser,
+				Repository: repo,
+				Language:   "go",
+				ReportedAt: start.Add(time.Duration(i) * 5 * time.Minute),
+			}))
+		}
+	}
+	now := time.Date(2025, time.March, 12, 9, 0, 0, 0, time.UTC)
+	yesterday := now.AddDate(0, 0, -1)
+	insert(yesterday, 60, "shigure")
+	insert(yesterday.Add(3*time.Hour), 20, "site")
+	insert(now.AddDate(0, 0, -2), 40, "shigure")
+	// Today is not part of the digest yet
+	insert(now.Add(-time.Hour), 30, "site")
+
+	digest, err := client.Digest(ctx, DigestDaily, now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, time.March, 11, 0, 0, 0, 0, time.UTC), digest.From)
+	assert.Equal(t, time.Date(2025, time.March, 12, 0, 0, 0, 0, time.UTC), digest.To)
+	assert.Equal(t, 80*time.Minute, digest.TimeSpent)
+	assert.Equal(t, 40*time.Minute, digest.PreviousTimeSpent)
+	change, ok := digest.Change()
+	assert.True(t, ok)
+	assert.Equal(t, float64(100), change)
+
+	require.Len(t, digest.TopRepositories, 2)
+	assert.Equal(t, "shigure", digest.TopRepositories[0].Repository)
+	if assert.NotNil(t, digest.LongestSession) {
+		assert.Equal(t, "1h0m0s", digest.LongestSession.Duration)
+	}
+
+	_, err = client.Digest(ctx, "monthly", now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+package code
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	EditorVSCode = "vscode"
+
+	maxEditorLen = 31
+)
+
+// EditorActivity is a heartbeat reported by an editor plugin
+// describing what is currently being worked on.
+type EditorActivity struct {
+	// Editor identifies the editor, e.g. vscode, neovim,
+	// goland or zed.
+	Editor        string `json:"editor"`
+	RepositoryURL string `json:"repository,omitempty"`
+	Workspace     string `json:"workspace"`
+	Filename      string `json:"fileName"`
+	Language      string `json:"language"`
+	Row           uint   `json:"row"`
+	Col           uint   `json:"col"`
+	CodeChunk     string `json:"viewChunk"`
+	// Repository is the canonical identity of RepositoryURL,
+	// set when sanitized. It is what is stored and counted in
+	// stats, while RepositoryURL is kept for display.
+	Repository string `json:"-"`
+	// Time the heartbeat was recorded by the editor, defaults
+	// to when it was received. Editors that queue heartbeats
+	// while offline can send them later on.
+	Time time.Time `json:"time,omitzero"`
+}
+
+// VSCodeActivity is the payload of the original VS Code
+// extension, kept for compatibility.
+type VSCodeActivity struct {
+	RepositoryURL string `json:"repository,omitempty"`
+	Workspace     string `json:"workspace"`
+	Filename      string `json:"fileName"`
+	Language      string `json:"language"`
+	Row           uint   `json:"row"`
+	Col           uint   `json:"col"`
+	CodeChunk     string `json:"viewChunk"`
+}
+
+func (a VSCodeActivity) EditorActivity() EditorActivity {
+	return EditorActivity{
+		Editor:        EditorVSCode,
+		RepositoryURL: a.RepositoryURL,
+		Workspace:     a.Workspace,
+		Filename:      a.Filename,
+		Language:      a.Language,
+		Row:           a.Row,
+		Col:           a.Col,
+		CodeChunk:     a.CodeChunk,
+	}
+}
+
+// normalizeEditor lower cases and trims the editor name so
+// that e.g. "Neovim" and "neovim" are grouped together.
+func normalizeEditor(editor string) string {
+	editor = strings.ToLower(strings.TrimSpace(editor))
+	if runes := []rune(editor); len(runes) > maxEditorLen {
+		editor = string(runes[:maxEditorLen])
+	}
+	if editor == "" {
+		return "unknown"
+	}
+	return editor
+}
+
+// sanitizeActivity normalizes a, applies the redaction rules and
+// masks secrets in the code chunk. The time of a is set to now if
+// it is missing or in the future.
+//
+// The canonical identity of the repository, following aliases if
+// any, is set alongside the reported remote.
+//
+// It returns the sanitized activity alongside the redaction decision
+// and the number of secrets masked.
+func sanitizeActivity(a EditorActivity, rules *redactionRules, aliases *repositoryAliases, now time.Time) (EditorActivity, redactionDecision, int) {
+	a.Editor = normalizeEditor(a.Editor)
+	a.Repository = aliases.resolve(NormalizeRepository(a.RepositoryURL))
+
+	if a.Time.IsZero() || a.Time.After(now) {
+		a.Time = now
+	}
+
+	if a.Filename != "" {
+		a.Filename = path.Base(strings.ReplaceAll(a.Filename, "\\", "/"))
+		if a.Filename == "." || a.Filename == "/" || a.Filename == `\` {
+			a.Filename = ""
+		}
+	}
+	parts := strings.FieldsFunc(a.Filename, func(r rune) bool {
+		return r == '\\' || r == '/'
+	})
+	if len(a.Filename) > 0 {
+		a.Filename = parts[len(parts)-1]
+	}
+	a.Language = NormalizeLanguage(a.Language, a.Filename)
+
+	redaction := rules.evaluate(a)
+	a = redaction.apply(a)
+
+	numSecrets := 0
+	if !redaction.has(RedactHideChunk) {
+		a.CodeChunk, numSecrets = maskSecrets(a.CodeChunk, a.Filename)
+	}
+
+	return a, redaction, numSecrets
+}
+
+func (a EditorActivity) codeActivity(user string, redaction redactionDecision) CodeActivity {
+	return CodeActivity{
+		User:       user,
+		Editor:     a.Editor,
+		Repository: a.Repository,
+		Workspace:  a.Workspace,
+		Filename:   a.Filename,
+		Language:   a.Language,
+		Row:        a.Row,
+		Col:        a.Col,
+		CodeChunk:  a.CodeChunk,
+		ReportedAt: a.Time,
+		Excluded:   redaction.has(RedactExcludeStats),
+	}
+}
@@ -0,0 +1,104 @@
+package code
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tifye/shigure/mux"
+)
+
+func newTestClient(t *testing.T) *ActivityClient {
+	t.Helper()
+	rr, err := newRedactionRules(filepath.Join(t.TempDir(), "rules.json"), "")
+	require.NoError(t, err)
+
+	c := &ActivityClient{
+		logger:               log.New(io.Discard),
+		activity:             defaultAcitivty,
+		lastUpdate:           atomic.Value{},
+		cardCache:            map[string]cachedCard{},
+		store:                newTestStore(t),
+		redaction:            map[string]*redactionRules{OwnerUser: rr},
+		mux:                  mux.NewMux(log.New(io.Discard)),
+		muxMessageType:       "editor",
+		legacyMuxMessageType: "vscode",
+		location:             time.UTC,
+		presence:             PresenceOffline,
+		presenceThresholds:   DefaultPresenceThresholds,
+		placeholder:          defaultAcitivty,
+	}
+	c.lastUpdate.Store(time.Now().Add(-time.Hour))
+	return c
+}
+
+func TestSetActivityEditors(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	require.NoError(t, client.SetActivity(ctx, OwnerUser, VSCodeActivity{Filename: "main.go"}.EditorActivity()))
+	require.NoError(t, client.SetActivity(ctx, OwnerUser, EditorActivity{Editor: " Neovim ", Filename: "init.lua"}))
+	assert.Equal(t, "neovim", client.Activity().Editor)
+
+	// Queued heartbeats are stored without replacing the current activity
+	require.NoError(t, client.SetActivity(ctx, OwnerUser, EditorActivity{
+		Editor:   "zed",
+		Filename: "lib.rs",
+		Time:     time.Now().Add(-30 * time.Minute),
+	}))
+	assert.Equal(t, "init.lua", client.Activity().Filename)
+
+	editors, err := client.store.EditorsReports(ctx, StatsFilter{})
+	require.NoError(t, err)
+	require.Len(t, editors, 3)
+	assert.ElementsMatch(t, []string{"vscode", "neovim", "zed"}, []string{editors[0].Editor, editors[1].Editor, editors[2].Editor})
+
+	zed, err := client.store.EditorsReports(ctx, StatsFilter{Editor: "zed"})
+	require.NoError(t, err)
+	assert.Len(t, zed, 1)
+}
+
+func TestSetActivityUsers(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+	aliceRules, err := newRedactionRules(filepath.Join(t.TempDir(), "alice.json"), "")
+	require.NoError(t, err)
+	client.redaction["alice"] = aliceRules
+
+	_, err = client.PutRedactionRule(ctx, "alice", RedactionRule{Repository: "secret", Actions: []RedactionAction{RedactDontStore}})
+	require.NoError(t, err)
+
+	now := time.Now()
+	require.NoError(t, client.SetActivity(ctx, OwnerUser, EditorActivity{Editor: "vscode", RepositoryURL: "secret", Language: "go", Time: now.Add(-10 * time.Minute)}))
+	require.NoError(t, client.SetActivity(ctx, OwnerUser, EditorActivity{Editor: "vscode", RepositoryURL: "secret", Language: "go", Time: now}))
+	for i := range 3 {
+		at := now.Add(time.Duration(i-2) * 10 * time.Minute)
+		require.NoError(t, client.SetActivity(ctx, "alice", EditorActivity{Editor: "zed", RepositoryURL: "site", Language: "rust", Time: at}))
+	}
+	require.NoError(t, client.SetActivity(ctx, "alice", EditorActivity{Editor: "zed", RepositoryURL: "secret", Time: now}))
+
+	assert.Equal(t, "vscode", client.Activity().Editor, "expected only the owner's activity to be current")
+	assert.ErrorIs(t, client.SetActivity(ctx, "Not A User", EditorActivity{}), ErrInvalidUser)
+
+	owner, err := client.store.RepositoryReports(ctx, StatsFilter{User: OwnerUser})
+	require.NoError(t, err)
+	if assert.Len(t, owner, 1) {
+		assert.Equal(t, "secret", owner[0].Repository, "expected alice's rules to not apply to the owner")
+	}
+
+	leaderboard, err := client.Leaderboard(ctx, StatsFilter{})
+	require.NoError(t, err)
+	if assert.Len(t, leaderboard, 2) {
+		alice := leaderboard[0]
+		alice.LastReported = time.Time{}
+		assert.Equal(t, LeaderboardEntry{Rank: 1, User: "alice", TimeSpent: "20m0s", Seconds: 20 * 60, TopLanguage: "Rust"}, alice)
+		assert.Equal(t, OwnerUser, leaderboard[1].User)
+		assert.Equal(t, "10m0s", leaderboard[1].TimeSpent)
+	}
+}
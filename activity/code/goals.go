@@ -0,0 +1,467 @@
+package code
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/tifye/shigure/assert"
+	"github.com/tifye/shigure/mux"
+)
+
+type GoalPeriod string
+
+const (
+	GoalDaily  GoalPeriod = "daily"
+	GoalWeekly GoalPeriod = "weekly"
+)
+
+const (
+	DefaultGoalsPath = "./data/codeGoals.json"
+
+	maxGoals = 50
+)
+
+var (
+	ErrGoalNotFound = errors.New("goal not found")
+	ErrInvalidGoal  = errors.New("invalid goal")
+)
+
+// Goal is an amount of the owner's coding time to reach every day
+// or week, overall or only in a language or repository.
+type Goal struct {
+	ID         string     `json:"id"`
+	Period     GoalPeriod `json:"period"`
+	Minutes    uint       `json:"minutes"`
+	Language   string     `json:"language,omitempty"`
+	Repository string     `json:"repository,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func (g Goal) validate() error {
+	if g.Period != GoalDaily && g.Period != GoalWeekly {
+		return fmt.Errorf("%w: unknown period %q", ErrInvalidGoal, g.Period)
+	}
+	maxMinutes := uint(24 * 60)
+	if g.Period == GoalWeekly {
+		maxMinutes *= 7
+	}
+	if g.Minutes == 0 || g.Minutes > maxMinutes {
+		return fmt.Errorf("%w: minutes must be between 1 and %d", ErrInvalidGoal, maxMinutes)
+	}
+	if len(g.Language) > 32 || len(g.Repository) > 128 {
+		return fmt.Errorf("%w: language or repository too long", ErrInvalidGoal)
+	}
+	return nil
+}
+
+// periodRange returns the period of g containing t.
+func (g Goal) periodRange(t time.Time) (from, to time.Time) {
+	preset := RangeToday
+	if g.Period == GoalWeekly {
+		preset = RangeThisWeek
+	}
+	from, to, err := RangePreset(preset, t)
+	assert.Assert(err == nil, "expected goal period to be a valid preset")
+	return from, to
+}
+
+func (g Goal) String() string {
+	target := (time.Duration(g.Minutes) * time.Minute).String()
+	s := fmt.Sprintf("%s goal of %s", g.Period, target)
+	if g.Language != "" {
+		s += " in " + g.Language
+	}
+	if g.Repository != "" {
+		s += " on " + g.Repository
+	}
+	return s
+}
+
+type GoalProgress struct {
+	Goal
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	TimeSpent string    `json:"timeSpent"`
+	Seconds   uint      `json:"seconds"`
+	// Percent of the goal reached, can go above 100.
+	Percent float64 `json:"percent"`
+	Reached bool    `json:"reached"`
+}
+
+// storedGoal is a goal as persisted, alongside the start of
+// the latest period the goal has been notified about.
+type storedGoal struct {
+	Goal
+	NotifiedPeriod time.Time `json:"notifiedPeriod,omitzero"`
+}
+
+// GoalNotifier posts message somewhere the owner will see it.
+type GoalNotifier func(ctx context.Context, message string) error
+
+type GoalTrackerOptions struct {
+	// Path the goals are persisted at, defaults to
+	// DefaultGoalsPath.
+	Path string
+	// Location of the day and week boundaries of goal
+	// periods. Defaults to UTC.
+	Location *time.Location
+	// Notify is called when a goal has been reached or
+	// missed, notifications are skipped when nil.
+	Notify GoalNotifier
+	// CanonicalRepository returns the identity the activity of
+	// a repository is stored under, following aliases. Defaults
+	// to NormalizeRepository.
+	CanonicalRepository func(repo string) string
+}
+
+// GoalTracker evaluates the owner's goals, broadcasting their
+// progress and notifying when they are reached or missed.
+type GoalTracker struct {
+	logger   *log.Logger
+	store    *CodeActivityStore
+	location *time.Location
+	notify   GoalNotifier
+	// canonical is the CanonicalRepository option
+	canonical func(repo string) string
+
+	mux            *mux.Mux
+	muxMessageType string
+
+	goals []storedGoal
+	path  string
+	mu    sync.Mutex
+
+	// lastProgress is the last broadcast progress,
+	// guarded by mu.
+	lastProgress []byte
+}
+
+func NewGoalTracker(
+	logger *log.Logger,
+	mux *mux.Mux,
+	store *CodeActivityStore,
+	opts GoalTrackerOptions,
+) (*GoalTracker, error) {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(mux)
+	assert.AssertNotNil(store)
+
+	if opts.Path == "" {
+		opts.Path = DefaultGoalsPath
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.CanonicalRepository == nil {
+		opts.CanonicalRepository = NormalizeRepository
+	}
+
+	gt := &GoalTracker{
+		logger:         logger,
+		store:          store,
+		location:       opts.Location,
+		notify:         opts.Notify,
+		canonical:      opts.CanonicalRepository,
+		mux:            mux,
+		muxMessageType: "codegoals",
+		goals:          []storedGoal{},
+		path:           opts.Path,
+	}
+
+	contents, err := os.ReadFile(opts.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return gt, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, &gt.goals); err != nil {
+		return nil, fmt.Errorf("unmarshal goals: %s", err)
+	}
+	migrated := false
+	for i, g := range gt.goals {
+		// Goals predating canonical repositories hold remotes
+		repo := gt.canonical(g.Repository)
+		migrated = migrated || repo != g.Repository
+		gt.goals[i].Repository = repo
+	}
+	if migrated {
+		return gt, gt.save()
+	}
+
+	return gt, nil
+}
+
+func (gt *GoalTracker) MessageType() string {
+	return gt.muxMessageType
+}
+
+func (gt *GoalTracker) HandleMessage(_ *mux.Channel, _ []byte) error {
+	return nil
+}
+
+// HandleSubscription sends the latest progress to channels
+// subscribing to goal progress.
+func (gt *GoalTracker) HandleSubscription(c *mux.Channel, typ mux.MessageType, didSub bool) {
+	if !didSub {
+		return
+	}
+
+	gt.mu.Lock()
+	progress := gt.lastProgress
+	gt.mu.Unlock()
+	if progress == nil {
+		return
+	}
+
+	err := gt.mux.SendSession(c.Session().ID(), typ, progress, func(ch *mux.Channel) bool {
+		return ch.ID() != c.ID()
+	})
+	if err != nil {
+		gt.logger.Error("send goal progress", "err", err)
+	}
+}
+
+func (gt *GoalTracker) Goals() []Goal {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+
+	goals := make([]Goal, len(gt.goals))
+	for i, g := range gt.goals {
+		goals[i] = g.Goal
+	}
+	return goals
+}
+
+// PutGoal adds a new goal, or replaces the goal with the same ID
+// if it is set. Replacing a goal keeps its notification state.
+func (gt *GoalTracker) PutGoal(goal Goal) (Goal, error) {
+	if goal.Language != "" {
+		goal.Language = NormalizeLanguage(goal.Language, "")
+	}
+	goal.Repository = gt.canonical(goal.Repository)
+	if err := goal.validate(); err != nil {
+		return Goal{}, err
+	}
+
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+
+	if goal.ID == "" {
+		if len(gt.goals) >= maxGoals {
+			return Goal{}, fmt.Errorf("%w: too many goals", ErrInvalidGoal)
+		}
+		goal.ID = newID()
+		goal.CreatedAt = time.Now()
+		gt.goals = append(gt.goals, storedGoal{Goal: goal})
+		return goal, gt.save()
+	}
+
+	idx := slices.IndexFunc(gt.goals, func(g storedGoal) bool {
+		return g.ID == goal.ID
+	})
+	if idx < 0 {
+		return Goal{}, ErrGoalNotFound
+	}
+	goal.CreatedAt = gt.goals[idx].CreatedAt
+	gt.goals[idx].Goal = goal
+
+	return goal, gt.save()
+}
+
+func (gt *GoalTracker) RemoveGoal(id string) error {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+
+	n := len(gt.goals)
+	gt.goals = slices.DeleteFunc(gt.goals, func(g storedGoal) bool {
+		return g.ID == id
+	})
+	if len(gt.goals) == n {
+		return ErrGoalNotFound
+	}
+
+	return gt.save()
+}
+
+// save persists the goals, expects gt.mu to be held.
+func (gt *GoalTracker) save() error {
+	contents, err := json.MarshalIndent(gt.goals, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(gt.path), 0755); err != nil {
+		return err
+	}
+	tempPath := gt.path + "_temp"
+	if err := os.WriteFile(tempPath, contents, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, gt.path)
+}
+
+// Progress evaluates every goal over its current period.
+func (gt *GoalTracker) Progress(ctx context.Context) ([]GoalProgress, error) {
+	now := time.Now().In(gt.location)
+	goals := gt.Goals()
+
+	progress := make([]GoalProgress, len(goals))
+	for i, g := range goals {
+		from, to := g.periodRange(now)
+		p, err := gt.progress(ctx, g, from, to)
+		if err != nil {
+			return nil, err
+		}
+		progress[i] = p
+	}
+	return progress, nil
+}
+
+func (gt *GoalTracker) progress(ctx context.Context, g Goal, from, to time.Time) (GoalProgress, error) {
+	// Aliases may have been added since the goal was
+	ts, err := gt.store.TotatTimeSpent(ctx, StatsFilter{
+		User:       OwnerUser,
+		From:       from,
+		To:         to,
+		Language:   g.Language,
+		Repository: gt.canonical(g.Repository),
+	})
+	if err != nil {
+		return GoalProgress{}, fmt.Errorf("get time spent on goal %s: %s", g.ID, err)
+	}
+
+	timeSpent := time.Duration(ts.Seconds * float64(time.Second)).Truncate(time.Second)
+	target := time.Duration(g.Minutes) * time.Minute
+	percent := float64(timeSpent) / float64(target) * 100
+	return GoalProgress{
+		Goal:      g,
+		From:      from,
+		To:        to,
+		TimeSpent: timeSpent.String(),
+		Seconds:   uint(timeSpent.Seconds()),
+		Percent:   math.Floor(percent*100) / 100,
+		Reached:   timeSpent >= target,
+	}, nil
+}
+
+// Run checks the goals every minute until ctx is done.
+func (gt *GoalTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		gt.check(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check broadcasts the progress of all goals if it changed, and
+// notifies about goals reached in the current period or missed in
+// the previous one. Each period is notified about at most once.
+func (gt *GoalTracker) check(ctx context.Context, now time.Time) {
+	now = now.In(gt.location)
+
+	gt.mu.Lock()
+	goals := slices.Clone(gt.goals)
+	gt.mu.Unlock()
+
+	var (
+		progress = make([]GoalProgress, 0, len(goals))
+		notified = map[string]time.Time{}
+	)
+	for _, g := range goals {
+		from, to := g.periodRange(now)
+
+		prevFrom, prevTo := g.periodRange(from.Add(-time.Nanosecond))
+		if g.NotifiedPeriod.Before(prevFrom) && g.CreatedAt.Before(prevFrom) {
+			prev, err := gt.progress(ctx, g.Goal, prevFrom, prevTo)
+			if err != nil {
+				gt.logger.Error("goal progress", "err", err)
+				continue
+			}
+			if gt.notifyProgress(ctx, prev) {
+				notified[g.ID] = prevFrom
+			}
+		}
+
+		p, err := gt.progress(ctx, g.Goal, from, to)
+		if err != nil {
+			gt.logger.Error("goal progress", "err", err)
+			continue
+		}
+		progress = append(progress, p)
+
+		if p.Reached && g.NotifiedPeriod.Before(from) {
+			if gt.notifyProgress(ctx, p) {
+				notified[g.ID] = from
+			}
+		}
+	}
+
+	msg, err := json.Marshal(progress)
+	if err != nil {
+		gt.logger.Error("marshal goal progress", "err", err)
+		return
+	}
+
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+
+	if len(notified) > 0 {
+		for i, g := range gt.goals {
+			if period, ok := notified[g.ID]; ok {
+				gt.goals[i].NotifiedPeriod = period
+			}
+		}
+		if err := gt.save(); err != nil {
+			gt.logger.Error("save goals", "err", err)
+		}
+	}
+
+	if bytes.Equal(msg, gt.lastProgress) {
+		return
+	}
+	gt.lastProgress = msg
+	if err := gt.mux.Broadcast(gt.muxMessageType, msg, nil); err != nil {
+		gt.logger.Error("broadcast goal progress", "err", err)
+	}
+}
+
+// notifyProgress reports whether the notification was sent, or
+// there is nothing to send it with.
+func (gt *GoalTracker) notifyProgress(ctx context.Context, p GoalProgress) bool {
+	if gt.notify == nil {
+		return true
+	}
+
+	var message string
+	if p.Reached {
+		message = fmt.Sprintf("🎯 Reached the %s with %s (%.0f%%)", p.Goal, p.TimeSpent, p.Percent)
+	} else {
+		message = fmt.Sprintf("Missed the %s with %s (%.0f%%)", p.Goal, p.TimeSpent, p.Percent)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := gt.notify(ctx, message); err != nil {
+		gt.logger.Error("notify goal progress", "err", err, "goal", p.ID)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package code
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tifye/shigure/mux"
+)
+
+func TestGoalTrackerNotifications(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	var messages []string
+	path := filepath.Join(t.TempDir(), "goals.json")
+	gt, err := NewGoalTracker(log.New(io.Discard), mux.NewMux(log.New(io.Discard)), store, GoalTrackerOptions{
+		Path: path,
+		Notify: func(_ context.Context, message string) error {
+			messages = append(messages, message)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = gt.PutGoal(Goal{Period: "hourly", Minutes: 10})
+	assert.ErrorIs(t, err, ErrInvalidGoal)
+
+	goal, err := gt.PutGoal(Goal{Period: GoalDaily, Minutes: 20, Language: "go"})
+	require.NoError(t, err)
+	gt.goals[0].CreatedAt = time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	now := time.Date(2025, time.March, 11, 15, 0, 0, 0, time.UTC)
+	insert := func(at time.Time, minutes int) {
+		for i := range minutes/5 + 1 {
+			require.NoError(t, store.Insert(ctx, CodeActivity{
+				User:       OwnerUser,
+				Language:   "Go",
+				ReportedAt: at.Add(time.Duration(i) * 5 * time.Minute),
+			}))
+		}
+	}
+	// 10 minutes yesterday and 30 minutes today
+	insert(now.AddDate(0, 0, -1), 10)
+	insert(now.Add(-time.Hour), 30)
+
+	gt.check(ctx, now)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "Missed the daily goal of 20m0s in Go with 10m0s (50%)", messages[0])
+	assert.Equal(t, "🎯 Reached the daily goal of 20m0s in Go with 30m0s (150%)", messages[1])
+
+	// Periods are only notified about once, even across restarts
+	gt.check(ctx, now.Add(time.Minute))
+	reloaded, err := NewGoalTracker(log.New(io.Discard), mux.NewMux(log.New(io.Discard)), store, GoalTrackerOptions{
+		Path:   path,
+		Notify: gt.notify,
+	})
+	require.NoError(t, err)
+	reloaded.check(ctx, now.Add(2*time.Minute))
+	assert.Len(t, messages, 2)
+
+	progress, err := reloaded.Progress(ctx)
+	require.NoError(t, err)
+	if assert.Len(t, progress, 1) {
+		assert.Equal(t, goal.ID, progress[0].ID)
+	}
+
+	require.NoError(t, reloaded.RemoveGoal(goal.ID))
+	assert.ErrorIs(t, reloaded.RemoveGoal(goal.ID), ErrGoalNotFound)
+}
+
+func TestGoalTrackerRepositories(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	dir := t.TempDir()
+
+	aliases, err := newRepositoryAliases(filepath.Join(dir, "aliases.json"))
+	require.NoError(t, err)
+	require.NoError(t, aliases.put(RepositoryAlias{From: "github.com/tifye/old", To: "github.com/tifye/new"}))
+	canonical := func(repo string) string {
+		return aliases.resolve(NormalizeRepository(repo))
+	}
+
+	// Saved before repositories were canonical
+	path := filepath.Join(dir, "goals.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"id":"a","period":"daily","minutes":20,"repository":"https://github.com/tifye/old.git"}]`), 0644))
+	gt, err := NewGoalTracker(log.New(io.Discard), mux.NewMux(log.New(io.Discard)), store, GoalTrackerOptions{
+		Path:                path,
+		CanonicalRepository: canonical,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/tifye/new", gt.Goals()[0].Repository)
+
+	goal, err := gt.PutGoal(Goal{Period: GoalDaily, Minutes: 20, Repository: "git@github.com:tifye/old.git"})
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/tifye/new", goal.Repository)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for i := range 3 {
+		require.NoError(t, store.Insert(ctx, CodeActivity{
+			User:       OwnerUser,
+			Repository: "github.com/tifye/new",
+			ReportedAt: today.Add(time.Duration(i) * 5 * time.Minute),
+		}))
+	}
+	progress, err := gt.Progress(ctx)
+	require.NoError(t, err)
+	require.Len(t, progress, 2)
+	for _, p := range progress {
+		assert.Equal(t, "10m0s", p.TimeSpent)
+	}
+}
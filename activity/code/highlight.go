@@ -0,0 +1,125 @@
+package code
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind uint8
+
+const (
+	tokenText tokenKind = iota
+	tokenKeyword
+	tokenString
+	tokenComment
+	tokenNumber
+)
+
+type token struct {
+	Kind tokenKind
+	Text string
+}
+
+// keywords is a deliberately loose union of keywords across
+// common languages. It is only used to colour the code chunk
+// and does not have to be correct for every language.
+var keywords = map[string]struct{}{}
+
+func init() {
+	for _, kw := range strings.Fields(`
+		break case catch class const continue def default defer do elif else
+		enum export extends false fn for from func function go if impl import
+		in interface let local match mod mut new nil none null package pub
+		return select self static struct switch then this throw true try type
+		undefined use var where while with yield async await`) {
+		keywords[kw] = struct{}{}
+	}
+}
+
+// lineCommentPrefix returns the line comment prefix for
+// language, defaulting to C-style comments.
+func lineCommentPrefix(language string) string {
+	switch strings.ToLower(language) {
+	case "python", "shellscript", "shell", "bash", "ruby", "perl", "r", "yaml", "toml", "dockerfile", "makefile", "powershell", "elixir", "nix":
+		return "#"
+	case "lua", "sql", "haskell":
+		return "--"
+	case "plaintext", "text", "markdown", "json":
+		return ""
+	default:
+		return "//"
+	}
+}
+
+// highlightLine splits a single line of code into tokens. It
+// does not carry any state between lines, so multi-line
+// strings and block comments are only partially highlighted.
+func highlightLine(line string, commentPrefix string) []token {
+	var (
+		tokens []token
+		text   strings.Builder
+	)
+	flushText := func() {
+		if text.Len() > 0 {
+			tokens = append(tokens, token{Kind: tokenText, Text: text.String()})
+			text.Reset()
+		}
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		rest := string(runes[i:])
+
+		switch {
+		case commentPrefix != "" && strings.HasPrefix(rest, commentPrefix),
+			strings.HasPrefix(rest, "/*"):
+			flushText()
+			tokens = append(tokens, token{Kind: tokenComment, Text: rest})
+			return tokens
+		case r == '"' || r == '\'' || r == '`':
+			flushText()
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				if runes[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(runes))
+			tokens = append(tokens, token{Kind: tokenString, Text: string(runes[i:end])})
+			i = end
+		case unicode.IsDigit(r) && (i == 0 || !isIdentRune(runes[i-1])):
+			flushText()
+			end := i
+			for end < len(runes) && (isIdentRune(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{Kind: tokenNumber, Text: string(runes[i:end])})
+			i = end
+		case isIdentRune(r):
+			end := i
+			for end < len(runes) && isIdentRune(runes[end]) {
+				end++
+			}
+			word := string(runes[i:end])
+			if _, ok := keywords[word]; ok {
+				flushText()
+				tokens = append(tokens, token{Kind: tokenKeyword, Text: word})
+			} else {
+				text.WriteString(word)
+			}
+			i = end
+		default:
+			text.WriteRune(r)
+			i++
+		}
+	}
+
+	flushText()
+	return tokens
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
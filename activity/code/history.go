@@ -0,0 +1,108 @@
+package code
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultHistoryLimit = 50
+	MaxHistoryLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// HistorySpan is a stretch of time spent on a single file.
+type HistorySpan struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Duration   string    `json:"duration"`
+	Editor     string    `json:"editor"`
+	Repository string    `json:"repository,omitempty"`
+	Workspace  string    `json:"workspace"`
+	Filename   string    `json:"fileName"`
+	Language   string    `json:"language"`
+}
+
+type History struct {
+	Spans []HistorySpan `json:"spans"`
+	// NextCursor continues the history where this page ended,
+	// it is empty when there are no more spans.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// History returns a page of the timeline of what was worked on,
+// most recent first. An empty cursor starts from the most recent
+// span.
+func (c *ActivityClient) History(ctx context.Context, filter StatsFilter, cursor string, limit uint) (History, error) {
+	if limit == 0 {
+		limit = DefaultHistoryLimit
+	}
+	limit = min(limit, MaxHistoryLimit)
+
+	before, err := decodeHistoryCursor(cursor)
+	if err != nil {
+		return History{}, err
+	}
+
+	// Fetch one extra span to know whether there is a next page
+	stored, err := c.store.Spans(ctx, filter, before, limit+1)
+	if err != nil {
+		return History{}, fmt.Errorf("get stored spans: %s", err)
+	}
+
+	history := History{Spans: make([]HistorySpan, 0, min(uint(len(stored)), limit))}
+	for i, s := range stored {
+		if uint(i) == limit {
+			last := stored[i-1]
+			history.NextCursor = encodeHistoryCursor(SpanKey{Start: last.Start, User: last.User})
+			break
+		}
+
+		duration := time.Duration(s.Seconds * float64(time.Second)).Truncate(time.Second)
+		history.Spans = append(history.Spans, HistorySpan{
+			Start:      s.Start,
+			End:        s.Start.Add(duration),
+			Duration:   duration.String(),
+			Editor:     s.Editor,
+			Repository: s.Repository,
+			Workspace:  s.Workspace,
+			Filename:   s.Filename,
+			Language:   s.Language,
+		})
+	}
+
+	return history, nil
+}
+
+// The cursor is the start and user of the last span of the
+// previous page, kept opaque so that it can change later on.
+func encodeHistoryCursor(key SpanKey) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(key.Start.UnixMicro(), 10) + ":" + key.User))
+}
+
+func decodeHistoryCursor(cursor string) (SpanKey, error) {
+	if cursor == "" {
+		return SpanKey{}, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return SpanKey{}, ErrInvalidCursor
+	}
+	start, user, ok := strings.Cut(string(b), ":")
+	if !ok || user == "" {
+		return SpanKey{}, ErrInvalidCursor
+	}
+	micros, err := strconv.ParseInt(start, 10, 64)
+	if err != nil || micros <= 0 {
+		return SpanKey{}, ErrInvalidCursor
+	}
+
+	return SpanKey{Start: time.UnixMicro(micros).UTC(), User: user}, nil
+}
@@ -0,0 +1,89 @@
+package code
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	start := time.Date(2025, time.March, 11, 13, 0, 0, 0, time.UTC)
+	insert := func(offset time.Duration, filename string) {
+		require.NoError(t, client.store.Insert(ctx, CodeActivity{
+			User:       OwnerUser,
+			Editor:     EditorVSCode,
+			Repository: "shigure",
+			Filename:   filename,
+			Language:   "go",
+			ReportedAt: start.Add(offset),
+		}))
+	}
+	insert(0, "main.go")
+	insert(time.Minute, "main.go")
+	insert(2*time.Minute, "main.go")
+	insert(3*time.Minute, "store.go")
+	insert(4*time.Minute, "store.go")
+	// Idle in between, so a new span on the same file
+	insert(time.Hour, "main.go")
+	insert(time.Hour+time.Minute, "main.go")
+
+	filter := StatsFilter{User: OwnerUser}
+	page, err := client.History(ctx, filter, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page.Spans, 2)
+	assert.Equal(t, "main.go", page.Spans[0].Filename)
+	assert.Equal(t, start.Add(time.Hour), page.Spans[0].Start.UTC())
+	assert.Equal(t, "1m0s", page.Spans[0].Duration)
+	assert.Equal(t, "store.go", page.Spans[1].Filename)
+	assert.Equal(t, "1m0s", page.Spans[1].Duration)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = client.History(ctx, filter, page.NextCursor, 2)
+	require.NoError(t, err)
+	require.Len(t, page.Spans, 1)
+	assert.Equal(t, "main.go", page.Spans[0].Filename)
+	assert.Equal(t, start, page.Spans[0].Start.UTC())
+	assert.Equal(t, start.Add(3*time.Minute), page.Spans[0].End.UTC())
+	assert.Empty(t, page.NextCursor)
+
+	_, err = client.History(ctx, filter, "not a cursor", 2)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	// Spans sharing the start would be skipped without the user
+	_, err = client.History(ctx, filter, base64.RawURLEncoding.EncodeToString([]byte("1741698000000000")), 2)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestHistorySameStart(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	start := time.Date(2025, time.March, 11, 13, 0, 0, 0, time.UTC)
+	for _, user := range []string{"alice", "bob", "carol"} {
+		require.NoError(t, client.store.Insert(ctx, CodeActivity{
+			User:       user,
+			Editor:     EditorVSCode,
+			Repository: "shigure",
+			Filename:   "main.go",
+			Language:   "go",
+			ReportedAt: start,
+		}))
+	}
+
+	// Each page ends between spans of the same start
+	cursor := ""
+	for i := range 3 {
+		page, err := client.History(ctx, StatsFilter{}, cursor, 1)
+		require.NoError(t, err)
+		require.Len(t, page.Spans, 1, "page %d", i)
+		assert.Equal(t, start, page.Spans[0].Start.UTC())
+		cursor = page.NextCursor
+	}
+	assert.Empty(t, cursor)
+}
@@ -0,0 +1,103 @@
+package code
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/tifye/shigure/raster"
+)
+
+const (
+	imageCardWidth = 480
+	// imageScale is the pixel density cards are rasterized at
+	// so they stay sharp on high density displays.
+	imageScale = 2
+)
+
+// StreamImage renders the current activity as a raster card in
+// the layout of the SVG card using the named theme. Renders are
+// cached per theme and format until the activity changes.
+func (c *ActivityClient) StreamImage(out io.Writer, theme string, format raster.Format) error {
+	if theme == "" {
+		theme = DefaultSVGTheme
+	}
+	t, ok := svgThemes[theme]
+	if !ok {
+		return fmt.Errorf("unknown theme %q", theme)
+	}
+
+	c.mu.RLock()
+	activity, version := c.activity, c.version
+	c.mu.RUnlock()
+
+	key := fmt.Sprintf("image:%s:%s", theme, format)
+	c.cardMu.Lock()
+	cached, ok := c.cardCache[key]
+	c.cardMu.Unlock()
+	if ok && cached.version == version {
+		_, err := out.Write(cached.data)
+		return err
+	}
+
+	c.logger.Debug("re-building vscode activity image", "theme", theme, "format", format, "version", version)
+
+	var buf bytes.Buffer
+	if err := raster.Encode(&buf, renderActivityImage(activity, t), format); err != nil {
+		return err
+	}
+
+	c.cardMu.Lock()
+	c.cardCache[key] = cachedCard{version: version, data: buf.Bytes()}
+	c.cardMu.Unlock()
+
+	_, err := out.Write(buf.Bytes())
+	return err
+}
+
+func renderActivityImage(a EditorActivity, theme SVGTheme) *image.RGBA {
+	const s = imageScale
+	w, h := imageCardWidth*s, svgCardHeight*s
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	foreground := raster.MustParseHexColor(theme.Foreground)
+	muted := raster.MustParseHexColor(theme.Muted)
+	raster.FillRect(img, img.Bounds(), raster.MustParseHexColor(theme.Border))
+	raster.FillRect(img, img.Bounds().Inset(s), raster.MustParseHexColor(theme.Background))
+	raster.FillRect(img, image.Rect(0, 0, 4*s, h), raster.MustParseHexColor(theme.Accent))
+
+	header := raster.Face(14*s, false)
+	defer header.Close()
+	small := raster.Face(12*s, false)
+	defer small.Close()
+	mono := raster.Face(12*s, true)
+	defer mono.Close()
+
+	left, right := 16*s, w-16*s
+	language := raster.Truncate(small, a.Language, 140*s)
+	languageWidth := raster.TextWidth(small, language)
+	raster.DrawText(img, small, right-languageWidth, 22*s, muted, language)
+	filename := raster.Truncate(header, a.Filename, right-left-languageWidth-12*s)
+	raster.DrawText(img, header, left, 22*s, foreground, filename)
+	raster.DrawText(img, small, left, 40*s, muted, raster.Truncate(small, cardRepository(a), right-left))
+
+	colors := map[tokenKind]string{
+		tokenText:    theme.Foreground,
+		tokenKeyword: theme.Keyword,
+		tokenString:  theme.String,
+		tokenComment: theme.Comment,
+		tokenNumber:  theme.Number,
+	}
+	for i, line := range highlightCodeChunk(a) {
+		x, y := left, (svgCodeTop+i*svgLineHeight)*s
+		for _, tok := range line {
+			raster.DrawText(img, mono, x, y, raster.MustParseHexColor(colors[tok.Kind]), tok.Text)
+			x += raster.TextWidth(mono, tok.Text)
+		}
+	}
+
+	position := fmt.Sprintf("%s · Ln %d, Col %d", a.Editor, a.Row, a.Col)
+	raster.DrawText(img, small, right-raster.TextWidth(small, position), (svgCardHeight-10)*s, muted, position)
+	return img
+}
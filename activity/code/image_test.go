@@ -0,0 +1,48 @@
+package code
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tifye/shigure/raster"
+)
+
+func TestStreamImage(t *testing.T) {
+	client := &ActivityClient{
+		logger:    log.New(io.Discard),
+		cardCache: map[string]cachedCard{},
+		activity: EditorActivity{
+			Editor:    "vscode",
+			Filename:  "main.go",
+			Language:  "Go",
+			CodeChunk: "func main() {\n\tfmt.Println(\"hi\")\n}",
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, client.StreamImage(&buf, "", raster.PNG))
+	img, name, err := image.Decode(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "png", name)
+	assert.Equal(t, image.Rect(0, 0, imageCardWidth*imageScale, svgCardHeight*imageScale), img.Bounds())
+
+	// Accent bar along the left edge
+	r, g, b, _ := img.At(2, img.Bounds().Dy()/2).RGBA()
+	accent := raster.MustParseHexColor(svgThemes[DefaultSVGTheme].Accent)
+	assert.Equal(t, [3]uint32{uint32(accent.R) * 0x101, uint32(accent.G) * 0x101, uint32(accent.B) * 0x101}, [3]uint32{r, g, b})
+
+	// Cached until the activity changes
+	cached := buf.Bytes()
+	client.activity.Filename = "other.go"
+	buf.Reset()
+	require.NoError(t, client.StreamImage(&buf, DefaultSVGTheme, raster.PNG))
+	assert.Equal(t, cached, buf.Bytes())
+
+	require.NoError(t, client.StreamImage(io.Discard, "light", raster.WebP))
+	assert.Error(t, client.StreamImage(io.Discard, "neon", raster.PNG))
+}
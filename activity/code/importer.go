@@ -0,0 +1,63 @@
+package code
+
+import (
+	"context"
+	"time"
+
+	"github.com/tifye/shigure/assert"
+)
+
+// Importer stores historical activity, such as from other
+// trackers, applying the same normalization and redaction
+// rules as live activity.
+type Importer struct {
+	user      string
+	store     *CodeActivityStore
+	redaction *redactionRules
+	aliases   *repositoryAliases
+}
+
+// NewImporter creates an importer storing activity as user's,
+// redacted by the rules persisted at redactionRulesPath and with
+// repositories merged by the aliases persisted at aliasesPath.
+func NewImporter(store *CodeActivityStore, user string, redactionRulesPath string, aliasesPath string) (*Importer, error) {
+	assert.AssertNotNil(store)
+	assert.AssertNotEmpty(redactionRulesPath)
+	assert.AssertNotEmpty(aliasesPath)
+	if !ValidUser(user) {
+		return nil, ErrInvalidUser
+	}
+
+	rr, err := newRedactionRules(redactionRulesPath, "")
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := newRepositoryAliases(aliasesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Importer{
+		user:      user,
+		store:     store,
+		redaction: rr,
+		aliases:   aliases,
+	}, nil
+}
+
+// Import stores activities, skipping those that have
+// already been imported. It returns the amount of
+// activities stored.
+func (i *Importer) Import(ctx context.Context, activities []EditorActivity) (int64, error) {
+	now := time.Now()
+	cas := make([]CodeActivity, 0, len(activities))
+	for _, a := range activities {
+		a, redaction, _ := sanitizeActivity(a, i.redaction, i.aliases, now)
+		if redaction.has(RedactDontStore) {
+			continue
+		}
+		cas = append(cas, a.codeActivity(i.user, redaction))
+	}
+
+	return i.store.InsertMany(ctx, cas)
+}
@@ -0,0 +1,159 @@
+package code
+
+import (
+	"path"
+	"strings"
+)
+
+const (
+	// UnknownLanguage is used when neither the editor nor the
+	// filename tell the language.
+	UnknownLanguage = "Unknown"
+	// TextLanguage is plain text, editors use it as a fallback
+	// so the filename is checked first.
+	TextLanguage = "Text"
+
+	defaultLanguageColor = "#8b8b8b"
+	maxLanguageLen       = 32
+)
+
+type language struct {
+	name  string
+	color string
+	// ids are the language identifiers sent by editors, such as
+	// the VS Code language IDs, besides the lower cased name.
+	ids []string
+	// extensions, or full filenames such as Dockerfile, the
+	// language is detected from.
+	extensions []string
+}
+
+// languages maps editor language identifiers to canonical names
+// and colours, following GitHub Linguist. Variants like React
+// flavoured JavaScript are folded into their base language.
+var languages = []language{
+	{"Astro", "#ff5a03", nil, []string{".astro"}},
+	{"Batchfile", "#c1f12e", []string{"bat"}, []string{".bat", ".cmd"}},
+	{"C", "#555555", nil, []string{".c", ".h"}},
+	{"C#", "#178600", []string{"csharp"}, []string{".cs", ".csx"}},
+	{"C++", "#f34b7d", []string{"cpp", "cuda-cpp"}, []string{".cpp", ".cc", ".cxx", ".hpp", ".hh", ".hxx"}},
+	{"Clojure", "#db5855", nil, []string{".clj", ".cljs", ".cljc", ".edn"}},
+	{"CSS", "#663399", nil, []string{".css"}},
+	{"Dart", "#00b4ab", nil, []string{".dart"}},
+	{"Dockerfile", "#384d54", []string{"docker"}, []string{"Dockerfile", "Containerfile"}},
+	{"Elixir", "#6e4a7e", nil, []string{".ex", ".exs"}},
+	{"Erlang", "#b83998", nil, []string{".erl", ".hrl"}},
+	{"F#", "#b845fc", []string{"fsharp"}, []string{".fs", ".fsi", ".fsx"}},
+	{"Gleam", "#ffaff3", nil, []string{".gleam"}},
+	{"Go", "#00add8", []string{"golang"}, []string{".go"}},
+	{"Go Checksums", "#00add8", []string{"go.sum"}, []string{"go.sum"}},
+	{"Go Module", "#00add8", []string{"go.mod"}, []string{"go.mod"}},
+	{"GraphQL", "#e10098", nil, []string{".graphql", ".gql"}},
+	{"Haskell", "#5e5086", nil, []string{".hs", ".lhs"}},
+	{"HCL", "#844fba", []string{"terraform", "tf"}, []string{".tf", ".tfvars", ".hcl"}},
+	{"HTML", "#e34c26", nil, []string{".html", ".htm"}},
+	{"Java", "#b07219", nil, []string{".java"}},
+	{"JavaScript", "#f1e05a", []string{"javascriptreact", "js", "jsx"}, []string{".js", ".jsx", ".mjs", ".cjs"}},
+	{"JSON", "#292929", []string{"jsonc", "jsonl"}, []string{".json", ".jsonc", ".jsonl"}},
+	{"Julia", "#a270ba", nil, []string{".jl"}},
+	{"Kotlin", "#a97bff", nil, []string{".kt", ".kts"}},
+	{"Less", "#1d365d", nil, []string{".less"}},
+	{"Lua", "#000080", nil, []string{".lua"}},
+	{"Makefile", "#427819", []string{"make"}, []string{"Makefile", "makefile", "GNUmakefile", ".mk"}},
+	{"Markdown", "#083fa1", []string{"mdx"}, []string{".md", ".markdown", ".mdx"}},
+	{"Nix", "#7e7eff", nil, []string{".nix"}},
+	{"Objective-C", "#438eff", []string{"objective-c", "objc"}, nil},
+	{"OCaml", "#ef7a08", nil, []string{".ml", ".mli"}},
+	{"Perl", "#0298c3", nil, []string{".pl", ".pm"}},
+	{"PHP", "#4f5d95", nil, []string{".php"}},
+	{"PowerShell", "#012456", []string{"pwsh"}, []string{".ps1", ".psm1", ".psd1"}},
+	{"Python", "#3572a5", []string{"py"}, []string{".py", ".pyi"}},
+	{"R", "#198ce7", nil, []string{".r"}},
+	{"Ruby", "#701516", nil, []string{".rb"}},
+	{"Rust", "#dea584", nil, []string{".rs"}},
+	{"Scala", "#c22d40", nil, []string{".scala", ".sc"}},
+	{"SCSS", "#c6538c", []string{"sass"}, []string{".scss", ".sass"}},
+	{"Shell", "#89e051", []string{"shellscript", "bash", "sh", "zsh"}, []string{".sh", ".bash", ".zsh"}},
+	{"SQL", "#e38c00", nil, []string{".sql"}},
+	{"Svelte", "#ff3e00", nil, []string{".svelte"}},
+	{"Swift", "#f05138", nil, []string{".swift"}},
+	{TextLanguage, "", []string{"plaintext", "txt"}, []string{".txt"}},
+	{"TOML", "#9c4221", nil, []string{".toml"}},
+	{"TypeScript", "#3178c6", []string{"typescriptreact", "ts", "tsx"}, []string{".ts", ".tsx", ".mts", ".cts"}},
+	{"Vim Script", "#199f4b", []string{"viml", "vim"}, []string{".vim"}},
+	{"Vue", "#41b883", nil, []string{".vue"}},
+	{"XML", "#0060ac", nil, []string{".xml", ".xsd", ".svg"}},
+	{"YAML", "#cb171e", []string{"yml"}, []string{".yaml", ".yml"}},
+	{"Zig", "#ec915c", nil, []string{".zig"}},
+}
+
+var (
+	languageByID        = map[string]*language{}
+	languageByName      = map[string]*language{}
+	languageByExtension = map[string]*language{}
+)
+
+func init() {
+	for i := range languages {
+		l := &languages[i]
+		languageByName[l.name] = l
+		languageByID[strings.ToLower(l.name)] = l
+		for _, id := range l.ids {
+			languageByID[id] = l
+		}
+		for _, ext := range l.extensions {
+			languageByExtension[strings.ToLower(ext)] = l
+		}
+	}
+}
+
+// languagesVersion is to be bumped whenever NormalizeLanguage
+// changes, so that stored reports are normalized again.
+const languagesVersion = 1
+
+// NormalizeLanguage maps the language identifier sent by an editor,
+// e.g. typescriptreact, to its canonical name, e.g. TypeScript. The
+// language is detected from filename when it is missing, unknown or
+// plain text. Unknown identifiers that can't be detected are kept.
+func NormalizeLanguage(lang string, filename string) string {
+	lang = strings.TrimSpace(lang)
+	l, ok := languageByID[strings.ToLower(lang)]
+	if ok && l.name != TextLanguage {
+		return l.name
+	}
+
+	if detected, ok := detectLanguage(filename); ok {
+		return detected.name
+	}
+	if ok {
+		return l.name
+	}
+	if lang == "" || strings.EqualFold(lang, UnknownLanguage) {
+		return UnknownLanguage
+	}
+	if runes := []rune(lang); len(runes) > maxLanguageLen {
+		lang = string(runes[:maxLanguageLen])
+	}
+	return lang
+}
+
+func detectLanguage(filename string) (*language, bool) {
+	if filename == "" {
+		return nil, false
+	}
+	base := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if l, ok := languageByExtension[strings.ToLower(base)]; ok {
+		return l, true
+	}
+	l, ok := languageByExtension[strings.ToLower(path.Ext(base))]
+	return l, ok
+}
+
+// LanguageColor returns the colour of the canonical language name,
+// or a neutral grey for languages without one.
+func LanguageColor(name string) string {
+	if l, ok := languageByName[name]; ok && l.color != "" {
+		return l.color
+	}
+	return defaultLanguageColor
+}
@@ -0,0 +1,79 @@
+package code
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	tt := []struct {
+		language string
+		filename string
+		expected string
+	}{
+		{"typescriptreact", "App.tsx", "TypeScript"},
+		{"javascriptreact", "", "JavaScript"},
+		{"  Go ", "main.go", "Go"},
+		{"go.mod", "go.mod", "Go Module"},
+		{"", "main.rs", "Rust"},
+		{"plaintext", "Dockerfile", "Dockerfile"},
+		{"plaintext", "notes", "Text"},
+		{"", "", UnknownLanguage},
+		{"Probably english", "", "Probably english"},
+		{"prisma", "schema.prisma", "prisma"},
+		{"", `C:\src\Makefile`, "Makefile"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.language+"/"+tc.filename, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NormalizeLanguage(tc.language, tc.filename))
+		})
+	}
+}
+
+func TestStoreNormalizeLanguages(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	start := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+	rows := []struct{ language, filename string }{
+		{"javascript", "index.js"},
+		{"javascriptreact", "App.jsx"},
+		{"", "main.go"},
+		{"JavaScript", "util.js"},
+	}
+	for i, r := range rows {
+		require.NoError(t, store.Insert(ctx, CodeActivity{
+			User:       OwnerUser,
+			Filename:   r.filename,
+			Language:   r.language,
+			ReportedAt: start.Add(time.Duration(i) * 5 * time.Minute),
+		}))
+	}
+
+	n, err := store.NormalizeLanguages(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	reports, err := store.LanguagesReports(ctx, StatsFilter{})
+	require.NoError(t, err)
+	languages := make([]string, len(reports))
+	for i, r := range reports {
+		languages[i] = r.Language
+	}
+	assert.ElementsMatch(t, []string{"JavaScript", "Go"}, languages)
+
+	// Already normalized, reports are not scanned again
+	require.NoError(t, store.Insert(ctx, CodeActivity{
+		User:       OwnerUser,
+		Filename:   "main.go",
+		Language:   "go",
+		ReportedAt: start.Add(time.Hour),
+	}))
+	n, err = store.NormalizeLanguages(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
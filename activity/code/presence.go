@@ -0,0 +1,88 @@
+package code
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Presence tells how recently the owner sent a heartbeat.
+type Presence string
+
+const (
+	PresenceActive  Presence = "active"
+	PresenceIdle    Presence = "idle"
+	PresenceAway    Presence = "away"
+	PresenceOffline Presence = "offline"
+
+	presenceCheckInterval = 30 * time.Second
+)
+
+var ErrInvalidPresenceThresholds = errors.New("invalid presence thresholds")
+
+// PresenceThresholds are the durations without heartbeats after
+// which the owner is considered idle, away and offline. Once away
+// the current activity is replaced by the placeholder.
+type PresenceThresholds struct {
+	Idle    time.Duration
+	Away    time.Duration
+	Offline time.Duration
+}
+
+var DefaultPresenceThresholds = PresenceThresholds{
+	Idle:    5 * time.Minute,
+	Away:    15 * time.Minute,
+	Offline: time.Hour,
+}
+
+func (t PresenceThresholds) Validate() error {
+	if t.Idle <= 0 || t.Away <= t.Idle || t.Offline <= t.Away {
+		return fmt.Errorf("%w: expected 0 < idle (%s) < away (%s) < offline (%s)", ErrInvalidPresenceThresholds, t.Idle, t.Away, t.Offline)
+	}
+	return nil
+}
+
+// presence returns the presence after not having sent
+// a heartbeat for inactive.
+func (t PresenceThresholds) presence(inactive time.Duration) Presence {
+	switch {
+	case inactive >= t.Offline:
+		return PresenceOffline
+	case inactive >= t.Away:
+		return PresenceAway
+	case inactive >= t.Idle:
+		return PresenceIdle
+	default:
+		return PresenceActive
+	}
+}
+
+func (p Presence) showsPlaceholder() bool {
+	return p == PresenceAway || p == PresenceOffline
+}
+
+// LoadPlaceholderActivity reads the activity shown while the owner
+// is away from the JSON file at path.
+func LoadPlaceholderActivity(path string) (EditorActivity, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return EditorActivity{}, err
+	}
+
+	var a EditorActivity
+	if err := json.Unmarshal(contents, &a); err != nil {
+		return EditorActivity{}, fmt.Errorf("unmarshal placeholder activity: %s", err)
+	}
+	a.Editor = normalizeEditor(a.Editor)
+	a.Time = time.Time{}
+	return a, nil
+}
+
+// activityMessage is the broadcast payload, the activity
+// alongside the owner's presence.
+type activityMessage struct {
+	EditorActivity
+	Presence Presence `json:"presence"`
+}
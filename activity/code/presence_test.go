@@ -0,0 +1,53 @@
+package code
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresenceThresholds(t *testing.T) {
+	require.NoError(t, DefaultPresenceThresholds.Validate())
+	assert.ErrorIs(t, PresenceThresholds{Idle: time.Minute, Away: time.Minute, Offline: time.Hour}.Validate(), ErrInvalidPresenceThresholds)
+	assert.ErrorIs(t, PresenceThresholds{}.Validate(), ErrInvalidPresenceThresholds)
+
+	th := DefaultPresenceThresholds
+	assert.Equal(t, PresenceActive, th.presence(time.Minute))
+	assert.Equal(t, PresenceIdle, th.presence(th.Idle))
+	assert.Equal(t, PresenceAway, th.presence(th.Away+time.Minute))
+	assert.Equal(t, PresenceOffline, th.presence(2*th.Offline))
+}
+
+func TestCheckPresence(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	now := time.Now()
+	require.NoError(t, client.SetActivity(ctx, OwnerUser, EditorActivity{Filename: "main.go", Time: now}))
+	assert.Equal(t, PresenceActive, client.Presence())
+
+	client.checkPresence(now.Add(6 * time.Minute))
+	assert.Equal(t, PresenceIdle, client.Presence())
+	assert.Equal(t, "main.go", client.Activity().Filename)
+
+	client.checkPresence(now.Add(16 * time.Minute))
+	assert.Equal(t, PresenceAway, client.Presence())
+	assert.Equal(t, defaultAcitivty, client.Activity())
+
+	client.checkPresence(now.Add(2 * time.Hour))
+	assert.Equal(t, PresenceOffline, client.Presence())
+}
+
+func TestSetActivityStaleHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	// A heartbeat queued while offline is newer than the last
+	// one but doesn't bring the owner back
+	require.NoError(t, client.SetActivity(ctx, OwnerUser, EditorActivity{Filename: "main.go", Time: time.Now().Add(-30 * time.Minute)}))
+	assert.Equal(t, PresenceOffline, client.Presence())
+	assert.Equal(t, defaultAcitivty, client.Activity())
+}
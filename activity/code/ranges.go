@@ -0,0 +1,49 @@
+package code
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	RangeToday      = "today"
+	RangeYesterday  = "yesterday"
+	RangeThisWeek   = "week"
+	RangeLast7Days  = "7d"
+	RangeThisMonth  = "month"
+	RangeLast30Days = "30d"
+	RangeThisYear   = "year"
+	RangeAllTime    = "all"
+)
+
+// RangePreset returns the [from, to) time range of a named preset
+// relative to now. Day boundaries are computed in the location
+// of now, weeks start on Monday.
+func RangePreset(preset string, now time.Time) (from, to time.Time, err error) {
+	loc := now.Location()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	tomorrow := startOfDay.AddDate(0, 0, 1)
+
+	switch preset {
+	case RangeToday:
+		return startOfDay, tomorrow, nil
+	case RangeYesterday:
+		return startOfDay.AddDate(0, 0, -1), startOfDay, nil
+	case RangeThisWeek:
+		// time.Sunday == 0, shift so that Monday is the first day
+		daysSinceMonday := (int(now.Weekday()) + 6) % 7
+		return startOfDay.AddDate(0, 0, -daysSinceMonday), tomorrow, nil
+	case RangeLast7Days:
+		return startOfDay.AddDate(0, 0, -6), tomorrow, nil
+	case RangeThisMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc), tomorrow, nil
+	case RangeLast30Days:
+		return startOfDay.AddDate(0, 0, -29), tomorrow, nil
+	case RangeThisYear:
+		return time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, loc), tomorrow, nil
+	case RangeAllTime:
+		return time.Time{}, time.Time{}, nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown range preset %q", preset)
+	}
+}
@@ -0,0 +1,53 @@
+package code
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangePreset(t *testing.T) {
+	loc := time.FixedZone("UTC+9", 9*60*60)
+	// A Wednesday
+	now := time.Date(2025, time.March, 12, 15, 30, 0, 0, loc)
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2025, month, d, 0, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		preset string
+		from   time.Time
+		to     time.Time
+	}{
+		{preset: RangeToday, from: day(time.March, 12), to: day(time.March, 13)},
+		{preset: RangeYesterday, from: day(time.March, 11), to: day(time.March, 12)},
+		{preset: RangeThisWeek, from: day(time.March, 10), to: day(time.March, 13)},
+		{preset: RangeLast7Days, from: day(time.March, 6), to: day(time.March, 13)},
+		{preset: RangeThisMonth, from: day(time.March, 1), to: day(time.March, 13)},
+		{preset: RangeLast30Days, from: day(time.February, 11), to: day(time.March, 13)},
+		{preset: RangeThisYear, from: day(time.January, 1), to: day(time.March, 13)},
+		{preset: RangeAllTime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			from, to, err := RangePreset(tt.preset, now)
+			require.NoError(t, err)
+			assert.True(t, tt.from.Equal(from), "from: expected %s got %s", tt.from, from)
+			assert.True(t, tt.to.Equal(to), "to: expected %s got %s", tt.to, to)
+		})
+	}
+
+	_, _, err := RangePreset("fortnight", now)
+	assert.Error(t, err)
+}
+
+func TestRangePresetWeekStartsOnMonday(t *testing.T) {
+	sunday := time.Date(2025, time.March, 16, 8, 0, 0, 0, time.UTC)
+	from, _, err := RangePreset(RangeThisWeek, sunday)
+	require.NoError(t, err)
+	assert.Equal(t, time.Monday, from.Weekday())
+	assert.Equal(t, 10, from.Day())
+}
@@ -0,0 +1,370 @@
+package code
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type RedactionAction string
+
+const (
+	// RedactHideChunk replaces the code chunk.
+	RedactHideChunk RedactionAction = "hide_chunk"
+	// RedactHideFilename replaces the filename.
+	RedactHideFilename RedactionAction = "hide_filename"
+	// RedactHideRepository removes the repository and
+	// replaces the workspace name.
+	RedactHideRepository RedactionAction = "hide_repository"
+	// RedactExcludeStats stores the activity but leaves
+	// it out of all stats.
+	RedactExcludeStats RedactionAction = "exclude_stats"
+	// RedactDontStore never persists the activity. It is
+	// still shown as the current activity.
+	RedactDontStore RedactionAction = "dont_store"
+)
+
+var redactionActions = []RedactionAction{
+	RedactHideChunk,
+	RedactHideFilename,
+	RedactHideRepository,
+	RedactExcludeStats,
+	RedactDontStore,
+}
+
+const (
+	DefaultRedactionRulesPath = "./data/redactionRules.json"
+
+	redactedPlaceholder = "[REDACTED]"
+)
+
+var (
+	redactedCodeChunk = strings.Repeat(redactedPlaceholder+"\n", 10)
+
+	ErrRedactionRuleNotFound = errors.New("redaction rule not found")
+	ErrInvalidRedactionRule  = errors.New("invalid redaction rule")
+)
+
+// RedactionRule applies its actions to every activity matching
+// all of its non-empty patterns. Patterns are globs where * matches
+// any sequence of characters and ? a single character, unless
+// Regex is set in which case they are RE2 regular expressions.
+// Patterns always have to match the entire value.
+type RedactionRule struct {
+	ID         string            `json:"id"`
+	Repository string            `json:"repository,omitempty"`
+	Workspace  string            `json:"workspace,omitempty"`
+	Filename   string            `json:"filename,omitempty"`
+	Language   string            `json:"language,omitempty"`
+	Regex      bool              `json:"regex,omitempty"`
+	Actions    []RedactionAction `json:"actions"`
+}
+
+type compiledRedactionRule struct {
+	RedactionRule
+	repository *regexp.Regexp
+	workspace  *regexp.Regexp
+	filename   *regexp.Regexp
+	language   *regexp.Regexp
+}
+
+// patternToRegex converts a rule pattern into an anchored regular
+// expression. The same expression is used both by Go and DuckDB,
+// which are both RE2 based.
+func patternToRegex(pattern string, isRegex bool) string {
+	if isRegex {
+		return "^(?:" + pattern + ")$"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+func compileRedactionRule(rule RedactionRule) (compiledRedactionRule, error) {
+	if rule.Repository == "" && rule.Workspace == "" && rule.Filename == "" && rule.Language == "" {
+		return compiledRedactionRule{}, fmt.Errorf("%w: at least one pattern is required", ErrInvalidRedactionRule)
+	}
+	if len(rule.Actions) == 0 {
+		return compiledRedactionRule{}, fmt.Errorf("%w: at least one action is required", ErrInvalidRedactionRule)
+	}
+	for _, a := range rule.Actions {
+		if !slices.Contains(redactionActions, a) {
+			return compiledRedactionRule{}, fmt.Errorf("%w: unknown action %q", ErrInvalidRedactionRule, a)
+		}
+	}
+
+	rule.Repository = normalizeRepositoryPattern(rule.Repository, rule.Regex)
+	compiled := compiledRedactionRule{RedactionRule: rule}
+	patterns := []struct {
+		pattern string
+		re      **regexp.Regexp
+	}{
+		{rule.Repository, &compiled.repository},
+		{rule.Workspace, &compiled.workspace},
+		{rule.Filename, &compiled.filename},
+		{rule.Language, &compiled.language},
+	}
+	for _, p := range patterns {
+		if p.pattern == "" {
+			continue
+		}
+		if len(p.pattern) > 300 {
+			return compiledRedactionRule{}, fmt.Errorf("%w: pattern too long", ErrInvalidRedactionRule)
+		}
+		re, err := regexp.Compile(patternToRegex(p.pattern, rule.Regex))
+		if err != nil {
+			return compiledRedactionRule{}, fmt.Errorf("%w: %s", ErrInvalidRedactionRule, err)
+		}
+		*p.re = re
+	}
+
+	return compiled, nil
+}
+
+func (r compiledRedactionRule) matches(a EditorActivity) bool {
+	matchesField := func(re *regexp.Regexp, value string) bool {
+		return re == nil || re.MatchString(value)
+	}
+	// Repository patterns match either the reported remote or
+	// its canonical identity
+	matchesRepository := matchesField(r.repository, a.RepositoryURL) || matchesField(r.repository, a.Repository)
+	return matchesRepository &&
+		matchesField(r.workspace, a.Workspace) &&
+		matchesField(r.filename, a.Filename) &&
+		matchesField(r.language, a.Language)
+}
+
+type redactionDecision map[RedactionAction]struct{}
+
+func (d redactionDecision) has(action RedactionAction) bool {
+	_, ok := d[action]
+	return ok
+}
+
+// apply redacts the fields of a according to the decision.
+func (d redactionDecision) apply(a EditorActivity) EditorActivity {
+	if d.has(RedactHideChunk) {
+		a.CodeChunk = redactedCodeChunk
+	}
+	if d.has(RedactHideFilename) {
+		a.Filename = redactedPlaceholder
+	}
+	if d.has(RedactHideRepository) {
+		a.RepositoryURL = ""
+		a.Repository = ""
+		a.Workspace = redactedPlaceholder
+	}
+	return a
+}
+
+type redactionRules struct {
+	rules []compiledRedactionRule
+	mu    sync.RWMutex
+	path  string
+}
+
+// newRedactionRules loads the rules persisted at path. If there
+// are none yet, the legacy list of redacted repositories at
+// legacyPath is converted into hide_chunk rules.
+func newRedactionRules(path string, legacyPath string) (*redactionRules, error) {
+	rr := &redactionRules{
+		rules: []compiledRedactionRule{},
+		path:  path,
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if errors.Is(err, os.ErrNotExist) {
+		rules, err := readLegacyRedactedRepos(legacyPath)
+		if err != nil {
+			return nil, fmt.Errorf("read legacy redacted repos: %s", err)
+		}
+		for _, rule := range rules {
+			compiled, err := compileRedactionRule(rule)
+			if err != nil {
+				return nil, err
+			}
+			rr.rules = append(rr.rules, compiled)
+		}
+		if len(rr.rules) > 0 {
+			return rr, rr.save()
+		}
+		return rr, nil
+	}
+
+	var rules []RedactionRule
+	if err := json.Unmarshal(contents, &rules); err != nil {
+		return nil, fmt.Errorf("unmarshal redaction rules: %s", err)
+	}
+	migrated := false
+	for _, rule := range rules {
+		compiled, err := compileRedactionRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %s", rule.ID, err)
+		}
+		// Rules predating canonical repositories matched remotes verbatim
+		migrated = migrated || compiled.Repository != rule.Repository
+		rr.rules = append(rr.rules, compiled)
+	}
+	if migrated {
+		return rr, rr.save()
+	}
+
+	return rr, nil
+}
+
+// readLegacyRedactedRepos reads the newline separated list of
+// exactly matched repositories that predates redaction rules.
+func readLegacyRedactedRepos(path string) ([]RedactionRule, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []RedactionRule
+	for _, br := range bytes.Split(bytes.TrimSpace(contents), []byte("\n")) {
+		repo := string(bytes.TrimSpace(br))
+		if repo == "" {
+			continue
+		}
+		rules = append(rules, RedactionRule{
+			ID:         newID(),
+			Repository: regexp.QuoteMeta(repo),
+			Regex:      true,
+			Actions:    []RedactionAction{RedactHideChunk},
+		})
+	}
+	return rules, nil
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// evaluate returns the union of the actions of all rules
+// matching a.
+func (rr *redactionRules) evaluate(a EditorActivity) redactionDecision {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	decision := redactionDecision{}
+	for _, rule := range rr.rules {
+		if !rule.matches(a) {
+			continue
+		}
+		for _, action := range rule.Actions {
+			decision[action] = struct{}{}
+		}
+	}
+	return decision
+}
+
+func (rr *redactionRules) list() []RedactionRule {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	rules := make([]RedactionRule, len(rr.rules))
+	for i, r := range rr.rules {
+		rules[i] = r.RedactionRule
+	}
+	return rules
+}
+
+// put adds the rule, or replaces the rule with the same ID when
+// replace is set, once apply has succeeded with the rule as it is
+// kept. mu is held throughout so that concurrent puts and removes
+// can't come between checking for the rule and replacing it.
+func (rr *redactionRules) put(rule RedactionRule, replace bool, apply func(RedactionRule) error) (RedactionRule, error) {
+	compiled, err := compileRedactionRule(rule)
+	if err != nil {
+		return RedactionRule{}, err
+	}
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	idx := slices.IndexFunc(rr.rules, func(r compiledRedactionRule) bool {
+		return r.ID == rule.ID
+	})
+	if replace && idx < 0 {
+		return RedactionRule{}, ErrRedactionRuleNotFound
+	}
+
+	if err := apply(compiled.RedactionRule); err != nil {
+		return RedactionRule{}, err
+	}
+
+	if idx >= 0 {
+		rr.rules[idx] = compiled
+	} else {
+		rr.rules = append(rr.rules, compiled)
+	}
+	return compiled.RedactionRule, rr.save()
+}
+
+func (rr *redactionRules) remove(id string) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	n := len(rr.rules)
+	rr.rules = slices.DeleteFunc(rr.rules, func(r compiledRedactionRule) bool {
+		return r.ID == id
+	})
+	if len(rr.rules) == n {
+		return ErrRedactionRuleNotFound
+	}
+
+	return rr.save()
+}
+
+// save persists the rules, expects rr.mu to be held.
+func (rr *redactionRules) save() error {
+	rules := make([]RedactionRule, len(rr.rules))
+	for i, r := range rr.rules {
+		rules[i] = r.RedactionRule
+	}
+
+	contents, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(rr.path), 0755); err != nil {
+		return err
+	}
+
+	tempPath := rr.path + "_temp"
+	if err := os.WriteFile(tempPath, contents, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, rr.path)
+}
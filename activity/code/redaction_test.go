@@ -0,0 +1,195 @@
+package code
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactionRuleMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     RedactionRule
+		activity EditorActivity
+		expected bool
+	}{
+		{
+			name:     "glob repository",
+			rule:     RedactionRule{Repository: "https://github.com/work/*"},
+			activity: EditorActivity{RepositoryURL: "https://github.com/work/secret", Repository: "github.com/work/secret"},
+			expected: true,
+		},
+		{
+			name:     "glob must match entirely",
+			rule:     RedactionRule{Repository: "work/*"},
+			activity: EditorActivity{RepositoryURL: "https://github.com/work/secret", Repository: "github.com/work/secret"},
+			expected: false,
+		},
+		{
+			name:     "glob escapes regex characters",
+			rule:     RedactionRule{Filename: "*.env"},
+			activity: EditorActivity{Filename: "prod_env"},
+			expected: false,
+		},
+		{
+			name:     "regex filename",
+			rule:     RedactionRule{Filename: `\.env(\..+)?`, Regex: true},
+			activity: EditorActivity{Filename: ".env.local"},
+			expected: true,
+		},
+		{
+			name:     "all patterns must match",
+			rule:     RedactionRule{Workspace: "work", Language: "go"},
+			activity: EditorActivity{Workspace: "work", Language: "rust"},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Actions = []RedactionAction{RedactHideChunk}
+			compiled, err := compileRedactionRule(tt.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, compiled.matches(tt.activity))
+		})
+	}
+}
+
+func TestRedactionRuleValidation(t *testing.T) {
+	_, err := compileRedactionRule(RedactionRule{Actions: []RedactionAction{RedactHideChunk}})
+	assert.ErrorIs(t, err, ErrInvalidRedactionRule)
+
+	_, err = compileRedactionRule(RedactionRule{Filename: "*.go"})
+	assert.ErrorIs(t, err, ErrInvalidRedactionRule)
+
+	_, err = compileRedactionRule(RedactionRule{Filename: "*.go", Actions: []RedactionAction{"explode"}})
+	assert.ErrorIs(t, err, ErrInvalidRedactionRule)
+
+	_, err = compileRedactionRule(RedactionRule{Filename: "(", Regex: true, Actions: []RedactionAction{RedactHideChunk}})
+	assert.ErrorIs(t, err, ErrInvalidRedactionRule)
+}
+
+func TestRedactionRulesLegacyMigration(t *testing.T) {
+	dir := t.TempDir()
+	legacyPath := filepath.Join(dir, "redactedRepos")
+	rulesPath := filepath.Join(dir, "redactionRules.json")
+	require.NoError(t, os.WriteFile(legacyPath, []byte("https://github.com/a/b.c\n"), 0644))
+
+	rr, err := newRedactionRules(rulesPath, legacyPath)
+	require.NoError(t, err)
+	assert.True(t, rr.evaluate(EditorActivity{Repository: "github.com/a/b.c"}).has(RedactHideChunk))
+	assert.False(t, rr.evaluate(EditorActivity{Repository: "github.com/a/bxc"}).has(RedactHideChunk))
+
+	reloaded, err := newRedactionRules(rulesPath, "")
+	require.NoError(t, err)
+	assert.Equal(t, rr.list(), reloaded.list())
+}
+
+func TestRedactionRulesPut(t *testing.T) {
+	rr, err := newRedactionRules(filepath.Join(t.TempDir(), "redactionRules.json"), "")
+	require.NoError(t, err)
+	rule := RedactionRule{ID: "a", Language: "Go", Actions: []RedactionAction{RedactHideChunk}}
+	ok := func(RedactionRule) error { return nil }
+
+	_, err = rr.put(rule, true, ok)
+	assert.ErrorIs(t, err, ErrRedactionRuleNotFound)
+
+	// Rules are only kept once applied
+	_, err = rr.put(rule, false, func(RedactionRule) error { return errors.New("db gone") })
+	assert.Error(t, err)
+	assert.Empty(t, rr.list())
+
+	_, err = rr.put(rule, false, ok)
+	require.NoError(t, err)
+	rule.Actions = []RedactionAction{RedactHideFilename}
+	_, err = rr.put(rule, true, ok)
+	require.NoError(t, err)
+	assert.Equal(t, []RedactionRule{rule}, rr.list())
+}
+
+func TestApplyRedactionRule(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	at := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+	for i, filename := range []string{"main.go", ".env", "main.go", ".env"} {
+		require.NoError(t, store.Insert(ctx, CodeActivity{
+			User:       OwnerUser,
+			Repository: "shigure",
+			Filename:   filename,
+			CodeChunk:  "API_KEY=hunter2",
+			ReportedAt: at.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	affected, err := store.ApplyRedactionRule(ctx, OwnerUser, RedactionRule{
+		Filename: ".env",
+		Actions:  []RedactionAction{RedactHideChunk, RedactExcludeStats},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	var chunks []string
+	require.NoError(t, store.db.SelectContext(ctx, &chunks, `select code_chunk from code_activity where "filename" = '.env'`))
+	for _, chunk := range chunks {
+		assert.Equal(t, redactedCodeChunk, chunk)
+	}
+
+	languages, err := store.LanguagesReports(ctx, StatsFilter{})
+	require.NoError(t, err)
+	if assert.Len(t, languages, 1) {
+		assert.Equal(t, uint(2), languages[0].TimesReported)
+	}
+
+	affected, err = store.ApplyRedactionRule(ctx, OwnerUser, RedactionRule{
+		Repository: "shi*",
+		Actions:    []RedactionAction{RedactDontStore},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), affected)
+}
+
+func TestRepositoryRedactionRules(t *testing.T) {
+	ctx := context.Background()
+
+	rules := map[string]RedactionRule{
+		"glob":  {Repository: "https://github.com/tifye/private-*"},
+		"regex": {Repository: `^https://github\.com/tifye/private-.*`, Regex: true},
+	}
+	for name, rule := range rules {
+		t.Run(name, func(t *testing.T) {
+			rule.Actions = []RedactionAction{RedactExcludeStats}
+			compiled, err := compileRedactionRule(rule)
+			require.NoError(t, err)
+
+			assert.True(t, compiled.matches(EditorActivity{
+				RepositoryURL: "git@github.com:tifye/private-x.git",
+				Repository:    "github.com/tifye/private-x",
+			}))
+			assert.False(t, compiled.matches(EditorActivity{
+				RepositoryURL: "https://github.com/tifye/public",
+				Repository:    "github.com/tifye/public",
+			}))
+
+			// Stored reports only have the canonical identity
+			store := newTestStore(t)
+			at := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+			for i, repo := range []string{"github.com/tifye/private-x", "github.com/tifye/public"} {
+				require.NoError(t, store.Insert(ctx, CodeActivity{
+					User:       OwnerUser,
+					Repository: repo,
+					ReportedAt: at.Add(time.Duration(i) * time.Minute),
+				}))
+			}
+			affected, err := store.ApplyRedactionRule(ctx, OwnerUser, compiled.RedactionRule)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), affected)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package code
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultRepositoryAliasesPath = "./data/repositoryAliases.json"
+
+	maxAliasChain = 8
+)
+
+var (
+	ErrRepositoryAliasNotFound = errors.New("repository alias not found")
+	ErrInvalidRepositoryAlias  = errors.New("invalid repository alias")
+
+	// Remotes in the scp-like syntax of git, e.g.
+	// git@github.com:tifye/shigure.git
+	scpLikeRemote = regexp.MustCompile(`^(?:[^@/\s]+@)?([^:/\\\s]+\.[^:/\\\s]+):([^/\\].*)$`)
+	regexpEscape  = regexp.MustCompile(`\\(.)`)
+	// Schemes repository regexes may start with, e.g. ^https://
+	// or https?:\/\/
+	regexpSchemePrefix = regexp.MustCompile(`^\^?(?:https\??|http|ssh|git)(?::|\\:)(?:/|\\/){2}`)
+)
+
+// Stand-ins for the wildcards of globs while normalizing them, from
+// the private use area as they are not otherwise part of remotes.
+const (
+	globAnyPlaceholder    = "\ue000"
+	globSinglePlaceholder = "\ue001"
+)
+
+// repositoriesVersion is to be bumped whenever NormalizeRepository
+// changes, so that stored reports are normalized again.
+const repositoriesVersion = 1
+
+// NormalizeRepository returns the canonical identity of a repository
+// remote, host/owner/name in lower case. Credentials, ports, schemes,
+// the .git suffix and trailing slashes are removed so that the https
+// and ssh remotes of a repository share the same identity. Values that
+// aren't remotes, such as local paths, are returned as is.
+func NormalizeRepository(repo string) string {
+	repo = strings.TrimSpace(repo)
+	if repo == "" {
+		return ""
+	}
+
+	var host, p string
+	if m := scpLikeRemote.FindStringSubmatch(repo); m != nil {
+		host, p = m[1], m[2]
+	} else if u, err := url.Parse(repo); err == nil && u.Host != "" {
+		host, p = u.Hostname(), u.Path
+	} else if first, rest, ok := strings.Cut(repo, "/"); ok && strings.Contains(first, ".") && !strings.ContainsAny(first, ":\\") {
+		// Remotes without a scheme, e.g. github.com/tifye/shigure
+		host, p = first, rest
+	} else {
+		return repo
+	}
+
+	p = strings.Trim(p, "/")
+	p = strings.TrimSuffix(p, ".git")
+	p = strings.TrimRight(p, "/")
+	if p == "" {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(host + "/" + p)
+}
+
+// normalizeRepositoryPattern rewrites a redaction pattern written
+// against remotes to match their canonical identities instead, as
+// those are what is stored. Globs are normalized around their
+// wildcards, e.g. https://github.com/tifye/private-* becomes
+// github.com/tifye/private-*. Regexes matching exactly one
+// repository, such as those added by MarkRepoRedacted, are
+// normalized as such, others only lose a leading scheme.
+func normalizeRepositoryPattern(pattern string, isRegex bool) string {
+	if pattern == "" {
+		return ""
+	}
+	if !isRegex {
+		placeholders := strings.NewReplacer("*", globAnyPlaceholder, "?", globSinglePlaceholder)
+		wildcards := strings.NewReplacer(globAnyPlaceholder, "*", globSinglePlaceholder, "?")
+		return wildcards.Replace(NormalizeRepository(placeholders.Replace(pattern)))
+	}
+
+	literal := regexpEscape.ReplaceAllString(pattern, "$1")
+	if regexp.QuoteMeta(literal) != pattern {
+		return regexpSchemePrefix.ReplaceAllString(pattern, "")
+	}
+	return regexp.QuoteMeta(NormalizeRepository(literal))
+}
+
+// RepositoryAlias merges the activity in the repository From into
+// the repository To, e.g. after a rename or for a fork.
+type RepositoryAlias struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type repositoryAliases struct {
+	aliases map[string]string
+	path    string
+	mu      sync.RWMutex
+}
+
+func newRepositoryAliases(path string) (*repositoryAliases, error) {
+	ra := &repositoryAliases{
+		aliases: map[string]string{},
+		path:    path,
+	}
+
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ra, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var aliases []RepositoryAlias
+	if err := json.Unmarshal(contents, &aliases); err != nil {
+		return nil, fmt.Errorf("unmarshal repository aliases: %s", err)
+	}
+	for _, a := range aliases {
+		ra.aliases[a.From] = a.To
+	}
+
+	return ra, nil
+}
+
+// resolve follows the aliases of the canonical repository repo.
+func (ra *repositoryAliases) resolve(repo string) string {
+	if ra == nil || repo == "" {
+		return repo
+	}
+
+	ra.mu.RLock()
+	defer ra.mu.RUnlock()
+	return ra.resolveLocked(repo)
+}
+
+func (ra *repositoryAliases) resolveLocked(repo string) string {
+	for range maxAliasChain {
+		to, ok := ra.aliases[repo]
+		if !ok {
+			break
+		}
+		repo = to
+	}
+	return repo
+}
+
+func (ra *repositoryAliases) list() []RepositoryAlias {
+	ra.mu.RLock()
+	defer ra.mu.RUnlock()
+	return ra.sorted()
+}
+
+// sorted returns the aliases sorted by From, the caller
+// must hold mu.
+func (ra *repositoryAliases) sorted() []RepositoryAlias {
+	aliases := make([]RepositoryAlias, 0, len(ra.aliases))
+	for from, to := range ra.aliases {
+		aliases = append(aliases, RepositoryAlias{From: from, To: to})
+	}
+	slices.SortFunc(aliases, func(a, b RepositoryAlias) int {
+		return strings.Compare(a.From, b.From)
+	})
+	return aliases
+}
+
+// put adds the alias, or replaces the alias of the same repository.
+// The repositories of alias have to be canonical.
+func (ra *repositoryAliases) put(alias RepositoryAlias) error {
+	if alias.From == "" || alias.To == "" || alias.From == alias.To {
+		return fmt.Errorf("%w: from and to must be different repositories", ErrInvalidRepositoryAlias)
+	}
+	if len(alias.From) > 256 || len(alias.To) > 256 {
+		return fmt.Errorf("%w: repository too long", ErrInvalidRepositoryAlias)
+	}
+
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	if ra.resolveLocked(alias.To) == alias.From {
+		return fmt.Errorf("%w: %s is already an alias of %s", ErrInvalidRepositoryAlias, alias.To, alias.From)
+	}
+	ra.aliases[alias.From] = alias.To
+
+	return ra.save()
+}
+
+func (ra *repositoryAliases) remove(from string) error {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	if _, ok := ra.aliases[from]; !ok {
+		return ErrRepositoryAliasNotFound
+	}
+	delete(ra.aliases, from)
+
+	return ra.save()
+}
+
+// save persists the aliases, the caller must hold mu.
+func (ra *repositoryAliases) save() error {
+	contents, err := json.MarshalIndent(ra.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ra.path), 0755); err != nil {
+		return err
+	}
+
+	tempPath := ra.path + "_temp"
+	if err := os.WriteFile(tempPath, contents, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, ra.path)
+}
@@ -0,0 +1,165 @@
+package code
+
+import (
+	"math"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const maskedSecret = "[MASKED]"
+
+// secretPatterns match well known secret formats. The first
+// capture group, if any, is the part that gets masked.
+var secretPatterns = []*regexp.Regexp{
+	// PEM encoded private keys, possibly cut off by the chunk
+	regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY(?: BLOCK)?-----.*?(?:-----END [A-Z ]*PRIVATE KEY(?: BLOCK)?-----|\z)`),
+	// JSON Web Tokens
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`),
+	// AWS access key IDs
+	regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}\b`),
+	// AWS secret access keys
+	regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`),
+	// GitHub tokens
+	regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`),
+	// Slack tokens
+	regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`),
+	// Stripe and similar sk_live/pk_live keys
+	regexp.MustCompile(`\b[sr]k_(?:live|test)_[A-Za-z0-9]{16,}\b`),
+	// Google API keys
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),
+	// Credentials in connection strings
+	regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^:/\s@]+:([^@/\s]+)@`),
+}
+
+var (
+	// Only spaces and tabs as \s would match across lines
+	dotenvLine = regexp.MustCompile(`(?m)^([ \t]*(?:export[ \t]+)?[A-Za-z_][A-Za-z0-9_.-]*[ \t]*=[ \t]*)(.+?)[ \t]*$`)
+	// Candidates for the entropy check, long runs of characters
+	// typically found in base64 and hex encoded secrets.
+	entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/_=-]{20,}`)
+	// Assigned values such as `password: "..."` which are
+	// suspicious even at lower entropy.
+	suspiciousAssignment = regexp.MustCompile(`(?i)(?:password|passwd|secret|token|api_?key|access_?key|private_?key|client_?secret)["']?\s*[:=]\s*["']([^"'\s]{8,})["']`)
+)
+
+// isDotenvFile reports whether filename is a dotenv file,
+// such as .env, .env.local or production.env.
+func isDotenvFile(filename string) bool {
+	base := path.Base(filename)
+	return base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env")
+}
+
+// maskSecrets replaces secrets found in chunk with a mask and
+// returns the masked chunk along with the amount of secrets that
+// were masked. In dotenv files every assigned value is masked.
+func maskSecrets(chunk string, filename string) (string, int) {
+	count := 0
+
+	if isDotenvFile(filename) {
+		chunk = dotenvLine.ReplaceAllStringFunc(chunk, func(line string) string {
+			m := dotenvLine.FindStringSubmatch(line)
+			if m[2] == maskedSecret {
+				return line
+			}
+			count++
+			return m[1] + maskedSecret
+		})
+	}
+
+	for _, re := range secretPatterns {
+		chunk = replaceSecret(re, chunk, &count)
+	}
+	chunk = replaceSecret(suspiciousAssignment, chunk, &count)
+
+	chunk = entropyCandidate.ReplaceAllStringFunc(chunk, func(s string) string {
+		if !isHighEntropy(s) {
+			return s
+		}
+		count++
+		return maskedSecret
+	})
+
+	return chunk, count
+}
+
+// replaceSecret masks every match of re in s. If re has a capture
+// group only the first group is masked.
+func replaceSecret(re *regexp.Regexp, s string, count *int) string {
+	return re.ReplaceAllStringFunc(s, func(match string) string {
+		if match == maskedSecret {
+			return match
+		}
+		*count++
+		sub := re.FindStringSubmatchIndex(match)
+		if len(sub) < 4 || sub[2] < 0 {
+			return maskedSecret
+		}
+		return match[:sub[2]] + maskedSecret + match[sub[3]:]
+	})
+}
+
+// isHighEntropy reports whether s looks like a random string
+// rather than an identifier or a word.
+func isHighEntropy(s string) bool {
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			hasUpper = true
+		case r >= 'a' && r <= 'z':
+			hasLower = true
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		}
+	}
+
+	isHex := strings.Trim(strings.ToLower(s), "0123456789abcdef") == ""
+	switch {
+	case isHex:
+		// Hex digests have a maximum entropy of 4 bits per char
+		return len(s) >= 32 && hasDigit && shannonEntropy(s) > 3.0
+	case hasUpper && hasLower && hasDigit:
+		return shannonEntropy(s) > 4.2
+	default:
+		return false
+	}
+}
+
+func shannonEntropy(s string) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	freq := map[rune]float64{}
+	for _, r := range s {
+		freq[r]++
+	}
+	n := float64(len(s))
+	entropy := 0.0
+	for _, f := range freq {
+		p := f / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// secretFileRule is the rule hiding all files of the same type
+// as filename, used to auto-redact files in which secrets were
+// found.
+func secretFileRule(filename string) RedactionRule {
+	pattern := filename
+	switch {
+	case isDotenvFile(filename):
+		pattern = "*.env*"
+		if strings.HasPrefix(filename, ".env") {
+			pattern = ".env*"
+		}
+	case path.Ext(filename) != "":
+		pattern = "*" + path.Ext(filename)
+	}
+
+	return RedactionRule{
+		Filename: pattern,
+		Actions:  []RedactionAction{RedactHideChunk},
+	}
+}
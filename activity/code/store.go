@@ -2,13 +2,18 @@ package code
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/tifye/shigure/assert"
 	"github.com/tifye/shigure/storage"
 )
 
 type CodeActivity struct {
+	User       string    `db:"username"`
+	Editor     string    `db:"editor"`
 	Repository string    `db:"repository"`
 	Workspace  string    `db:"workspace"`
 	Filename   string    `db:"filename"`
@@ -17,22 +22,37 @@ type CodeActivity struct {
 	Col        uint      `db:"column"`
 	CodeChunk  string    `db:"code_chunk"`
 	ReportedAt time.Time `db:"reported_at"`
+	// Excluded reports are stored but left
+	// out of all stats.
+	Excluded bool `db:"excluded"`
 }
 
+// DefaultIdleTimeout is the longest gap between two reports
+// that still counts as time spent coding.
+const DefaultIdleTimeout = 15 * time.Minute
+
 type CodeActivityStore struct {
-	db storage.DuckDB
+	db          storage.DuckDB
+	idleTimeout time.Duration
 }
 
-func NewCodeActivityStore(db storage.DuckDB) *CodeActivityStore {
+// NewCodeActivityStore creates a store whose stats count the time
+// between consecutive reports as time spent, as long as it does
+// not exceed idleTimeout.
+func NewCodeActivityStore(db storage.DuckDB, idleTimeout time.Duration) *CodeActivityStore {
 	assert.AssertNotNil(db)
+	assert.Assert(idleTimeout > 0, "expected a positive idle timeout")
 	return &CodeActivityStore{
-		db: db,
+		db:          db,
+		idleTimeout: idleTimeout,
 	}
 }
 
 func (s *CodeActivityStore) Insert(ctx context.Context, ca CodeActivity) error {
 	query := `
 	insert into code_activity (
+		username,
+		editor,
 		repository,
 		workspace,
 		filename,
@@ -40,12 +60,15 @@ func (s *CodeActivityStore) Insert(ctx context.Context, ca CodeActivity) error {
 		"row",
 		"column",
 		code_chunk,
-		reported_at
+		reported_at,
+		excluded
 	)
-	values (?,?,?,?,?,?,?,?)
+	values (?,?,?,?,?,?,?,?,?,?,?)
 	`
 	_, err := s.db.ExecContext(
 		ctx, query,
+		ca.User,
+		ca.Editor,
 		ca.Repository,
 		ca.Workspace,
 		ca.Filename,
@@ -54,55 +77,479 @@ func (s *CodeActivityStore) Insert(ctx context.Context, ca CodeActivity) error {
 		ca.Col,
 		ca.CodeChunk,
 		ca.ReportedAt,
+		ca.Excluded,
 	)
 	return err
 }
 
+// InsertMany inserts all reports in a single transaction. Reports
+// which already exist, going by their user, editor, filename and
+// time, are skipped. It returns the number of inserted reports.
+func (s *CodeActivityStore) InsertMany(ctx context.Context, cas []CodeActivity) (int64, error) {
+	query := `
+	insert into code_activity (
+		username,
+		editor,
+		repository,
+		workspace,
+		filename,
+		language,
+		"row",
+		"column",
+		code_chunk,
+		reported_at,
+		excluded
+	)
+	select ?,?,?,?,?,?,?,?,?,?,?
+	where not exists (
+		select 1 from code_activity
+		where reported_at = ? and username = ? and editor = ? and "filename" = ?
+	)
+	`
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PreparexContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var inserted int64
+	for _, ca := range cas {
+		res, err := stmt.ExecContext(
+			ctx,
+			ca.User,
+			ca.Editor,
+			ca.Repository,
+			ca.Workspace,
+			ca.Filename,
+			ca.Language,
+			ca.Row,
+			ca.Col,
+			ca.CodeChunk,
+			ca.ReportedAt,
+			ca.Excluded,
+			ca.ReportedAt,
+			ca.User,
+			ca.Editor,
+			ca.Filename,
+		)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		inserted += n
+	}
+
+	return inserted, tx.Commit()
+}
+
+// ApplyRedactionRule retroactively applies the actions of rule
+// to every stored report of user it matches. It returns the number
+// of affected reports.
+func (s *CodeActivityStore) ApplyRedactionRule(ctx context.Context, user string, rule RedactionRule) (int64, error) {
+	var (
+		conds = []string{"username = ?"}
+		args  = []any{user}
+	)
+	patterns := []struct {
+		column  string
+		pattern string
+	}{
+		{"repository", rule.Repository},
+		{"workspace", rule.Workspace},
+		{`"filename"`, rule.Filename},
+		{`"language"`, rule.Language},
+	}
+	for _, p := range patterns {
+		if p.pattern == "" {
+			continue
+		}
+		conds = append(conds, fmt.Sprintf("regexp_matches(coalesce(%s, ''), ?)", p.column))
+		args = append(args, patternToRegex(p.pattern, rule.Regex))
+	}
+	assert.Assert(len(conds) > 1, "expected rule to have at least one pattern")
+	where := "where " + strings.Join(conds, " and ")
+
+	decision := redactionDecision{}
+	for _, a := range rule.Actions {
+		decision[a] = struct{}{}
+	}
+
+	if decision.has(RedactDontStore) {
+		res, err := s.db.ExecContext(ctx, "delete from code_activity "+where, args...)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+
+	var (
+		sets    []string
+		setArgs []any
+	)
+	if decision.has(RedactHideChunk) {
+		sets = append(sets, "code_chunk = ?")
+		setArgs = append(setArgs, redactedCodeChunk)
+	}
+	if decision.has(RedactHideFilename) {
+		sets = append(sets, `"filename" = ?`)
+		setArgs = append(setArgs, redactedPlaceholder)
+	}
+	if decision.has(RedactHideRepository) {
+		sets = append(sets, "repository = ''", "workspace = ?")
+		setArgs = append(setArgs, redactedPlaceholder)
+	}
+	if decision.has(RedactExcludeStats) {
+		sets = append(sets, "excluded = true")
+	}
+	if len(sets) == 0 {
+		return 0, nil
+	}
+
+	query := fmt.Sprintf("update code_activity set %s %s", strings.Join(sets, ", "), where)
+	res, err := s.db.ExecContext(ctx, query, append(setArgs, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// migrationVersion returns the version of the named rewrite of
+// stored reports last applied, zero if it never was.
+func (s *CodeActivityStore) migrationVersion(ctx context.Context, name string) (int, error) {
+	var version int
+	err := s.db.GetContext(ctx, &version, `
+	select coalesce(max("version"), 0) from code_activity_migrations where "name" = ?;
+	`, name)
+	return version, err
+}
+
+// markMigrated records the named rewrite of stored reports as
+// applied at version.
+func markMigrated(ctx context.Context, tx *sqlx.Tx, name string, version int) error {
+	_, err := tx.ExecContext(ctx, `
+	insert or replace into code_activity_migrations ("name", "version", applied_at)
+	values (?, ?, ?);
+	`, name, version, time.Now().UTC())
+	return err
+}
+
+// NormalizeLanguages rewrites the language of stored reports to
+// its canonical name, see NormalizeLanguage. It returns the number
+// of reports that changed. Reports are only rewritten once per
+// languagesVersion, as new ones are normalized when reported.
+func (s *CodeActivityStore) NormalizeLanguages(ctx context.Context) (int64, error) {
+	version, err := s.migrationVersion(ctx, "languages")
+	if err != nil {
+		return 0, err
+	}
+	if version >= languagesVersion {
+		return 0, nil
+	}
+
+	var pairs []struct {
+		Language string `db:"language"`
+		Filename string `db:"filename"`
+	}
+	err = s.db.SelectContext(ctx, &pairs, `
+	select distinct coalesce("language", '') as "language", coalesce("filename", '') as "filename"
+	from code_activity;
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var updated int64
+	for _, p := range pairs {
+		normalized := NormalizeLanguage(p.Language, p.Filename)
+		if normalized == p.Language {
+			continue
+		}
+		res, err := tx.ExecContext(ctx, `
+		update code_activity set "language" = ?
+		where coalesce("language", '') = ? and coalesce("filename", '') = ?;
+		`, normalized, p.Language, p.Filename)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		updated += n
+	}
+
+	if err := markMigrated(ctx, tx, "languages", languagesVersion); err != nil {
+		return 0, err
+	}
+	return updated, tx.Commit()
+}
+
+// RenameRepository moves the stored reports of all users in the
+// repository from to the repository to. It returns the number of
+// moved reports.
+func (s *CodeActivityStore) RenameRepository(ctx context.Context, from string, to string) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "update code_activity set repository = ? where repository = ?;", to, from)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// NormalizeRepositories rewrites the repository of stored reports
+// to canonical(repository). It returns the number of reports that
+// changed. Reports are only rewritten once per repositoriesVersion,
+// as new ones are normalized when reported and aliases are applied
+// to stored reports when added.
+func (s *CodeActivityStore) NormalizeRepositories(ctx context.Context, canonical func(string) string) (int64, error) {
+	version, err := s.migrationVersion(ctx, "repositories")
+	if err != nil {
+		return 0, err
+	}
+	if version >= repositoriesVersion {
+		return 0, nil
+	}
+
+	var repos []string
+	err = s.db.SelectContext(ctx, &repos, `
+	select distinct repository from code_activity where repository is not null;
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var updated int64
+	for _, repo := range repos {
+		normalized := canonical(repo)
+		if normalized == repo {
+			continue
+		}
+		res, err := tx.ExecContext(ctx, "update code_activity set repository = ? where repository = ?;", normalized, repo)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		updated += n
+	}
+
+	if err := markMigrated(ctx, tx, "repositories", repositoriesVersion); err != nil {
+		return 0, err
+	}
+	return updated, tx.Commit()
+}
+
+// StatsFilter narrows down which code activity reports
+// are taken into account by the stats queries. Zero values
+// are ignored. Excluded reports are never taken into account.
+type StatsFilter struct {
+	// User whose reports are taken into account, all
+	// users when empty.
+	User string
+	// From is inclusive.
+	From time.Time
+	// To is exclusive.
+	To         time.Time
+	Repository string
+	Language   string
+	Workspace  string
+	Editor     string
+}
+
+// where builds the SQL where clauses, including the where keyword,
+// of the reports in range, those of the user between From and To,
+// and of the reports in range matching the other fields, together
+// with the arguments of both in that order.
+func (f StatsFilter) where() (inRange string, matching string, args []any) {
+	var rangeConds, matchingConds []string
+	if f.User != "" {
+		rangeConds = append(rangeConds, "username = ?")
+		args = append(args, f.User)
+	}
+	if !f.From.IsZero() {
+		rangeConds = append(rangeConds, "reported_at >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		rangeConds = append(rangeConds, "reported_at < ?")
+		args = append(args, f.To.UTC())
+	}
+
+	matchingConds = append(matchingConds, "not coalesce(excluded, false)")
+	if f.Repository != "" {
+		matchingConds = append(matchingConds, "repository = ?")
+		args = append(args, f.Repository)
+	}
+	if f.Language != "" {
+		matchingConds = append(matchingConds, `"language" = ?`)
+		args = append(args, f.Language)
+	}
+	if f.Workspace != "" {
+		matchingConds = append(matchingConds, "workspace = ?")
+		args = append(args, f.Workspace)
+	}
+	if f.Editor != "" {
+		matchingConds = append(matchingConds, "editor = ?")
+		args = append(args, f.Editor)
+	}
+
+	if len(rangeConds) > 0 {
+		inRange = "where " + strings.Join(rangeConds, " and ")
+	}
+	return inRange, "where " + strings.Join(matchingConds, " and "), args
+}
+
+// durationsQuery yields a durations relation of each report matching
+// the where clauses together with the seconds until the next report,
+// which is the time attributed to the report. Gaps longer than the
+// idle timeout are idle time and count as zero, so the seconds of
+// any group of reports add up exactly to the time spent on them.
+// A new session starts after every idle gap. Each user's reports
+// are accounted for separately.
+//
+// Gaps and sessions are measured over all reports in range before
+// the others are filtered out, so that time spent on other reports,
+// including excluded ones, is never attributed to those matching.
+func (s *CodeActivityStore) durationsQuery(inRange string, matching string) string {
+	return fmt.Sprintf(`
+	with ranged as (
+		select reported_at, username, repository, workspace, "filename", "language", editor, excluded from code_activity
+		%[1]s
+	),
+	gaps as (
+		select
+			*,
+			coalesce(epoch(lead(reported_at) over byUser - reported_at), 0) as gap,
+			coalesce(epoch(reported_at - lag(reported_at) over byUser), 0) as prev_gap
+		from ranged
+		window byUser as (partition by username order by reported_at)
+	),
+	attributed as (
+		select
+			*,
+			case when gap <= %[3]f then gap else 0 end as seconds,
+			sum(case when prev_gap > %[3]f then 1 else 0 end)
+				over (partition by username order by reported_at rows unbounded preceding) as session_id
+		from gaps
+	),
+	durations as (
+		select
+			reported_at,
+			username,
+			repository,
+			workspace,
+			"filename",
+			"language",
+			editor,
+			seconds,
+			session_id
+		from attributed
+		%[2]s
+	)
+	`, inRange, matching, s.idleTimeout.Seconds())
+}
+
 type StoredRepositoryReport struct {
 	Repository    string `db:"repository"`
 	TimesReported uint   `db:"times_reported"`
-	// (TimesReported / AllReports) * 100
+	// Seconds spent on the repository.
+	Seconds float64 `db:"seconds"`
+	// (Seconds / TotalSeconds) * 100
 	OverallPercent float64   `db:"percent"`
 	LastReported   time.Time `db:"last_reported"`
 }
 
-func (s *CodeActivityStore) RepositoryReports(ctx context.Context) ([]StoredRepositoryReport, error) {
-	query := `
-	set variable total = (select count(*) from code_activity);
+func (s *CodeActivityStore) RepositoryReports(ctx context.Context, filter StatsFilter) ([]StoredRepositoryReport, error) {
+	inRange, matching, args := filter.where()
+	query := s.durationsQuery(inRange, matching) + `
 	select repository,
 		count(*) as times_reported,
-		times_reported / getvariable('total') * 100 as "percent",
+		sum(seconds) as seconds,
+		coalesce(sum(seconds) / nullif(sum(sum(seconds)) over (), 0) * 100, 0) as "percent",
 		max(reported_at) as last_reported
-	from code_activity
+	from durations
 	group by repository
-	order by times_reported desc;
+	order by seconds desc, times_reported desc;
 	`
 	var reports []StoredRepositoryReport
-	err := s.db.SelectContext(ctx, &reports, query)
+	err := s.db.SelectContext(ctx, &reports, query, args...)
 	return reports, err
 }
 
 type StoredLanguageReport struct {
 	Language      string `db:"language"`
 	TimesReported uint   `db:"times_reported"`
-	// (TimesReported / AllReports) * 100
+	// Seconds spent in the language.
+	Seconds float64 `db:"seconds"`
+	// (Seconds / TotalSeconds) * 100
 	OverallPercent float64   `db:"percent"`
 	LastReported   time.Time `db:"last_reported"`
 }
 
-func (s *CodeActivityStore) LanguagesReports(ctx context.Context) ([]StoredLanguageReport, error) {
-	query := `
-	set variable total = (select count(*) from code_activity);
-	select "language", 
+func (s *CodeActivityStore) LanguagesReports(ctx context.Context, filter StatsFilter) ([]StoredLanguageReport, error) {
+	inRange, matching, args := filter.where()
+	query := s.durationsQuery(inRange, matching) + `
+	select "language",
 		count(*) as times_reported,
-		times_reported / getvariable('total') * 100 as "percent",
+		sum(seconds) as seconds,
+		coalesce(sum(seconds) / nullif(sum(sum(seconds)) over (), 0) * 100, 0) as "percent",
 		max(reported_at) as last_reported
-	from code_activity
+	from durations
 	group by "language"
-	order by times_reported desc;
+	order by seconds desc, times_reported desc;
 	`
 	var reports []StoredLanguageReport
-	err := s.db.SelectContext(ctx, &reports, query)
+	err := s.db.SelectContext(ctx, &reports, query, args...)
+	return reports, err
+}
+
+type StoredEditorReport struct {
+	Editor        string `db:"editor"`
+	TimesReported uint   `db:"times_reported"`
+	// Seconds spent in the editor.
+	Seconds float64 `db:"seconds"`
+	// (Seconds / TotalSeconds) * 100
+	OverallPercent float64   `db:"percent"`
+	LastReported   time.Time `db:"last_reported"`
+}
+
+func (s *CodeActivityStore) EditorsReports(ctx context.Context, filter StatsFilter) ([]StoredEditorReport, error) {
+	inRange, matching, args := filter.where()
+	query := s.durationsQuery(inRange, matching) + `
+	select editor,
+		count(*) as times_reported,
+		sum(seconds) as seconds,
+		coalesce(sum(seconds) / nullif(sum(sum(seconds)) over (), 0) * 100, 0) as "percent",
+		max(reported_at) as last_reported
+	from durations
+	group by editor
+	order by seconds desc, times_reported desc;
+	`
+	var reports []StoredEditorReport
+	err := s.db.SelectContext(ctx, &reports, query, args...)
 	return reports, err
 }
 
@@ -118,18 +565,51 @@ type StoredSession struct {
 	TopRepositories []any `db:"top_repositories"`
 }
 
-func (s *CodeActivityStore) Sessions(ctx context.Context, limit uint) ([]StoredSession, error) {
+func (s *CodeActivityStore) Sessions(ctx context.Context, filter StatsFilter, limit uint) ([]StoredSession, error) {
 	assert.Assert(limit < 100, "limit too large")
 
-	query := `
-	select id, "start", "end", top_repositories from sessions
+	inRange, matching, args := filter.where()
+	query := s.durationsQuery(inRange, matching) + `
+	select
+		session_id as id,
+		min(reported_at) as "start",
+		max(reported_at) as "end",
+		approx_top_k(repository, 5) as top_repositories
+	from durations
+	group by username, session_id
+	order by "start" desc
 	limit ?
 	`
 	var sessions []StoredSession
-	err := s.db.SelectContext(ctx, &sessions, query, limit)
+	err := s.db.SelectContext(ctx, &sessions, query, append(args, limit)...)
 	return sessions, err
 }
 
+// LongestSession returns the longest session, or false
+// if there are none.
+func (s *CodeActivityStore) LongestSession(ctx context.Context, filter StatsFilter) (StoredSession, bool, error) {
+	inRange, matching, args := filter.where()
+	query := s.durationsQuery(inRange, matching) + `
+	select
+		session_id as id,
+		min(reported_at) as "start",
+		max(reported_at) as "end",
+		approx_top_k(repository, 5) as top_repositories
+	from durations
+	group by username, session_id
+	order by "end" - "start" desc
+	limit 1
+	`
+	var sessions []StoredSession
+	if err := s.db.SelectContext(ctx, &sessions, query, args...); err != nil {
+		return StoredSession{}, false, err
+	}
+	if len(sessions) == 0 {
+		return StoredSession{}, false, nil
+	}
+	return sessions[0], true, nil
+}
+
 type StoredTimeSpent struct {
 	Seconds float64 `db:"seconds"`
 	Minutes float64 `db:"minutes"`
@@ -138,10 +618,11 @@ type StoredTimeSpent struct {
 	Weeks   float64 `db:"weeks"`
 }
 
-func (s *CodeActivityStore) TotatTimeSpent(ctx context.Context) (StoredTimeSpent, error) {
-	query := `
-	with totalSeconds as (
-		select coalesce(sum(epoch(duration)), 0) as seconds from sessions
+func (s *CodeActivityStore) TotatTimeSpent(ctx context.Context, filter StatsFilter) (StoredTimeSpent, error) {
+	inRange, matching, args := filter.where()
+	query := s.durationsQuery(inRange, matching) + `,
+	totalSeconds as (
+		select coalesce(sum(seconds), 0) as seconds from durations
 	)
 	select 
 		seconds,
@@ -151,8 +632,214 @@ func (s *CodeActivityStore) TotatTimeSpent(ctx context.Context) (StoredTimeSpent
 		days/7 as weeks
 	from totalSeconds
 	`
-	row := s.db.QueryRowxContext(ctx, query)
+	row := s.db.QueryRowxContext(ctx, query, args...)
 	var timeSpent StoredTimeSpent
 	err := row.StructScan(&timeSpent)
 	return timeSpent, err
 }
+
+type StoredSpan struct {
+	User       string    `db:"username"`
+	Editor     string    `db:"editor"`
+	Repository string    `db:"repository"`
+	Workspace  string    `db:"workspace"`
+	Filename   string    `db:"filename"`
+	Language   string    `db:"language"`
+	Start      time.Time `db:"start"`
+	Seconds    float64   `db:"seconds"`
+}
+
+// SpanKey orders spans, by start and then by user as spans of
+// different users may start at the same time.
+type SpanKey struct {
+	Start time.Time
+	User  string
+}
+
+// Spans condenses consecutive reports of the same user on the
+// same file into spans, ordered by most recent first. Only spans
+// ordered after the exclusive before are returned, unless its
+// start is zero. A span ends after its seconds, which is when the
+// user moved on to another file or went idle.
+func (s *CodeActivityStore) Spans(ctx context.Context, filter StatsFilter, before SpanKey, limit uint) ([]StoredSpan, error) {
+	assert.Assert(limit <= 100, "limit too large")
+
+	inRange, matching, args := filter.where()
+	query := s.durationsQuery(inRange, matching) + `,
+	marked as (
+		select
+			*,
+			case
+				when session_id is distinct from lag(session_id) over byUser
+					or editor is distinct from lag(editor) over byUser
+					or repository is distinct from lag(repository) over byUser
+					or workspace is distinct from lag(workspace) over byUser
+					or "filename" is distinct from lag("filename") over byUser
+					or "language" is distinct from lag("language") over byUser
+				then 1 else 0
+			end as is_new_span
+		from durations
+		window byUser as (partition by username order by reported_at)
+	),
+	spanned as (
+		select
+			*,
+			sum(is_new_span) over (partition by username order by reported_at rows unbounded preceding) as span_id
+		from marked
+	),
+	spans as (
+		select
+			username,
+			any_value(editor) as editor,
+			coalesce(any_value(repository), '') as repository,
+			coalesce(any_value(workspace), '') as workspace,
+			coalesce(any_value("filename"), '') as "filename",
+			coalesce(any_value("language"), '') as "language",
+			min(reported_at) as "start",
+			sum(seconds) as seconds
+		from spanned
+		group by username, span_id
+	)
+	select * from spans
+	where ? or ("start", username) < (?, ?)
+	order by "start" desc, username desc
+	limit ?
+	`
+	args = append(args, before.Start.IsZero(), before.Start.UTC(), before.User, limit)
+	var spans []StoredSpan
+	err := s.db.SelectContext(ctx, &spans, query, args...)
+	return spans, err
+}
+
+type StoredLeaderboardEntry struct {
+	User         string    `db:"username"`
+	Seconds      float64   `db:"seconds"`
+	TopLanguage  string    `db:"top_language"`
+	LastReported time.Time `db:"last_reported"`
+}
+
+// Leaderboard returns the time spent per user, ordered by most
+// time spent first. The user of filter is ignored.
+func (s *CodeActivityStore) Leaderboard(ctx context.Context, filter StatsFilter, limit uint) ([]StoredLeaderboardEntry, error) {
+	assert.Assert(limit <= 100, "limit too large")
+
+	filter.User = ""
+	inRange, matching, args := filter.where()
+	query := s.durationsQuery(inRange, matching) + `,
+	languages as (
+		select username, "language", sum(seconds) as seconds
+		from durations
+		group by username, "language"
+	),
+	topLanguages as (
+		select username, arg_max("language", seconds) as top_language
+		from languages
+		group by username
+	)
+	select
+		d.username,
+		sum(d.seconds) as seconds,
+		coalesce(any_value(t.top_language), '') as top_language,
+		max(d.reported_at) as last_reported
+	from durations d
+	join topLanguages t on t.username = d.username
+	group by d.username
+	order by seconds desc, d.username
+	limit ?
+	`
+	var entries []StoredLeaderboardEntry
+	err := s.db.SelectContext(ctx, &entries, query, append(args, limit)...)
+	return entries, err
+}
+
+// localDurationsQuery extends durationsQuery with a localDurations
+// relation of each report with its time converted to a local
+// timestamp.
+//
+// The timezone is expected as an argument after those of the where clause.
+func (s *CodeActivityStore) localDurationsQuery(inRange string, matching string) string {
+	return s.durationsQuery(inRange, matching) + `,
+	localDurations as (
+		select
+			timezone(?, timezone('UTC', reported_at)) as local_at,
+			seconds
+		from durations
+	)
+	`
+}
+
+type StoredCalendarDay struct {
+	Date    time.Time `db:"date"`
+	Seconds float64   `db:"seconds"`
+}
+
+// CalendarDays returns the time spent per local day in loc. Days
+// without any reports are omitted.
+func (s *CodeActivityStore) CalendarDays(ctx context.Context, filter StatsFilter, loc *time.Location) ([]StoredCalendarDay, error) {
+	assert.AssertNotNil(loc)
+
+	inRange, matching, args := filter.where()
+	query := s.localDurationsQuery(inRange, matching) + `
+	select local_at::date as "date", sum(seconds) as seconds
+	from localDurations
+	group by "date"
+	order by "date"
+	`
+	var days []StoredCalendarDay
+	err := s.db.SelectContext(ctx, &days, query, append(args, loc.String())...)
+	return days, err
+}
+
+type StoredStreak struct {
+	Start time.Time `db:"start"`
+	End   time.Time `db:"end"`
+	Days  uint      `db:"days"`
+}
+
+// Streaks returns every run of consecutive local days in loc
+// with at least one report, ordered by most recent first.
+func (s *CodeActivityStore) Streaks(ctx context.Context, filter StatsFilter, loc *time.Location) ([]StoredStreak, error) {
+	assert.AssertNotNil(loc)
+
+	inRange, matching, args := filter.where()
+	query := s.localDurationsQuery(inRange, matching) + `,
+	activeDays as (
+		select distinct local_at::date as "day" from localDurations
+	),
+	islands as (
+		select "day", "day" - cast(row_number() over (order by "day") as integer) as grp
+		from activeDays
+	)
+	select min("day") as "start", max("day") as "end", count(*) as days
+	from islands
+	group by grp
+	order by "end" desc
+	`
+	var streaks []StoredStreak
+	err := s.db.SelectContext(ctx, &streaks, query, append(args, loc.String())...)
+	return streaks, err
+}
+
+type StoredHourlyActivity struct {
+	// ISO day of week, 1 = Monday through 7 = Sunday.
+	Weekday uint    `db:"weekday"`
+	Hour    uint    `db:"hour"`
+	Seconds float64 `db:"seconds"`
+}
+
+// HourlyActivity returns the time spent per local weekday and
+// hour of day in loc.
+func (s *CodeActivityStore) HourlyActivity(ctx context.Context, filter StatsFilter, loc *time.Location) ([]StoredHourlyActivity, error) {
+	assert.AssertNotNil(loc)
+
+	inRange, matching, args := filter.where()
+	query := s.localDurationsQuery(inRange, matching) + `
+	select isodow(local_at) as weekday, hour(local_at) as "hour", sum(seconds) as seconds
+	from localDurations
+	group by weekday, "hour"
+	order by weekday, "hour"
+	`
+	var activity []StoredHourlyActivity
+	err := s.db.SelectContext(ctx, &activity, query, append(args, loc.String())...)
+	return activity, err
+}
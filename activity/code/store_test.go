@@ -0,0 +1,151 @@
+package code
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tifye/shigure/storage"
+)
+
+func newTestStore(t *testing.T) *CodeActivityStore {
+	t.Helper()
+	db, err := storage.OpenDuckDB("")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return NewCodeActivityStore(db, DefaultIdleTimeout)
+}
+
+func TestStoreStatsFilter(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	start := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+	insert := func(at time.Time, repo, lang string) {
+		err := store.Insert(ctx, CodeActivity{
+			Repository: repo,
+			Workspace:  "ws",
+			Filename:   "main.go",
+			Language:   lang,
+			ReportedAt: at,
+		})
+		require.NoError(t, err)
+	}
+	// Day one, 30 minutes on shigure
+	for i := range 4 {
+		insert(start.Add(time.Duration(i)*10*time.Minute), "shigure", "go")
+	}
+	// Day two, 20 minutes on a different repository
+	for i := range 3 {
+		insert(start.AddDate(0, 0, 1).Add(time.Duration(i)*10*time.Minute), "site", "typescript")
+	}
+
+	all, err := store.TotatTimeSpent(ctx, StatsFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, float64(50*60), all.Seconds)
+
+	dayOne := StatsFilter{From: start.Truncate(24 * time.Hour), To: start.Truncate(24*time.Hour).AddDate(0, 0, 1)}
+	day, err := store.TotatTimeSpent(ctx, dayOne)
+	require.NoError(t, err)
+	assert.Equal(t, float64(30*60), day.Seconds)
+
+	repos, err := store.RepositoryReports(ctx, StatsFilter{Language: "typescript"})
+	require.NoError(t, err)
+	if assert.Len(t, repos, 1) {
+		assert.Equal(t, "site", repos[0].Repository)
+		assert.Equal(t, float64(100), repos[0].OverallPercent)
+	}
+
+	sessions, err := store.Sessions(ctx, StatsFilter{Repository: "shigure"}, 5)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+func TestStoreTimeSpentAddsUp(t *testing.T) {
+	ctx := context.Background()
+	db, err := storage.OpenDuckDB("")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	store := NewCodeActivityStore(db, 5*time.Minute)
+
+	at := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+	insert := func(repo, lang string, gap time.Duration) {
+		require.NoError(t, store.Insert(ctx, CodeActivity{Repository: repo, Language: lang, ReportedAt: at}))
+		at = at.Add(gap)
+	}
+	// shigure reports every 30 seconds, site only every 4 minutes
+	for range 10 {
+		insert("shigure", "go", 30*time.Second)
+	}
+	insert("site", "typescript", 4*time.Minute)
+	insert("site", "typescript", 4*time.Minute)
+	// Idle for longer than the timeout
+	insert("site", "css", time.Hour)
+	insert("shigure", "go", 2*time.Minute)
+	insert("shigure", "sql", 0)
+
+	total, err := store.TotatTimeSpent(ctx, StatsFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, float64(5*60+8*60+2*60), total.Seconds)
+
+	repos, err := store.RepositoryReports(ctx, StatsFilter{})
+	require.NoError(t, err)
+	if assert.Len(t, repos, 2) {
+		assert.Equal(t, "site", repos[0].Repository)
+		assert.Equal(t, float64(8*60), repos[0].Seconds)
+		assert.Equal(t, "shigure", repos[1].Repository)
+		assert.Equal(t, float64(7*60), repos[1].Seconds)
+		assert.InDelta(t, 100, repos[0].OverallPercent+repos[1].OverallPercent, 0.0001)
+	}
+
+	langs, err := store.LanguagesReports(ctx, StatsFilter{})
+	require.NoError(t, err)
+	var langSeconds float64
+	for _, l := range langs {
+		langSeconds += l.Seconds
+	}
+	assert.Equal(t, total.Seconds, langSeconds)
+
+	sessions, err := store.Sessions(ctx, StatsFilter{}, 5)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+}
+
+func TestStoreFilteredTimeSpent(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	start := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+	insert := func(minute int, repo string, excluded bool) {
+		err := store.Insert(ctx, CodeActivity{
+			Repository: repo,
+			Language:   "go",
+			ReportedAt: start.Add(time.Duration(minute) * time.Minute),
+			Excluded:   excluded,
+		})
+		require.NoError(t, err)
+	}
+	insert(0, "shigure", false)
+	insert(1, "site", false)
+	insert(3, "site", false)
+	insert(4, "shigure", false)
+	insert(5, "shigure", true)
+	insert(9, "site", false)
+	insert(10, "shigure", false)
+
+	repos, err := store.RepositoryReports(ctx, StatsFilter{})
+	require.NoError(t, err)
+	require.Len(t, repos, 2)
+	// Time after the excluded report is not attributed to anyone
+	assert.Equal(t, "site", repos[0].Repository)
+	assert.Equal(t, float64(4*60), repos[0].Seconds)
+	assert.Equal(t, float64(2*60), repos[1].Seconds)
+
+	for _, r := range repos {
+		filtered, err := store.TotatTimeSpent(ctx, StatsFilter{Repository: r.Repository})
+		require.NoError(t, err)
+		assert.Equal(t, r.Seconds, filtered.Seconds, r.Repository)
+	}
+}
@@ -0,0 +1,256 @@
+package code
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/tifye/shigure/assert"
+	"github.com/tifye/shigure/raster"
+)
+
+const (
+	svgMaxLines     = 14
+	svgMaxLineWidth = 64
+	svgLineHeight   = 16
+	svgCodeTop      = 66
+	svgCardHeight   = svgCodeTop + svgMaxLines*svgLineHeight + 20
+)
+
+type SVGTheme struct {
+	Background string
+	Border     string
+	Foreground string
+	Muted      string
+	Accent     string
+	Keyword    string
+	String     string
+	Comment    string
+	Number     string
+}
+
+var svgThemes = map[string]SVGTheme{
+	"dark": {
+		Background: "#1e1e1e",
+		Border:     "#333333",
+		Foreground: "#d4d4d4",
+		Muted:      "#858585",
+		Accent:     "#007acc",
+		Keyword:    "#569cd6",
+		String:     "#ce9178",
+		Comment:    "#6a9955",
+		Number:     "#b5cea8",
+	},
+	"light": {
+		Background: "#ffffff",
+		Border:     "#e5e5e5",
+		Foreground: "#3b3b3b",
+		Muted:      "#6e7781",
+		Accent:     "#005fb8",
+		Keyword:    "#0000ff",
+		String:     "#a31515",
+		Comment:    "#008000",
+		Number:     "#098658",
+	},
+	"monokai": {
+		Background: "#272822",
+		Border:     "#3e3d32",
+		Foreground: "#f8f8f2",
+		Muted:      "#90908a",
+		Accent:     "#a6e22e",
+		Keyword:    "#f92672",
+		String:     "#e6db74",
+		Comment:    "#75715e",
+		Number:     "#ae81ff",
+	},
+}
+
+const DefaultSVGTheme = "dark"
+
+// SVGThemes returns the names of the built-in themes.
+func SVGThemes() []string {
+	names := make([]string, 0, len(svgThemes))
+	for name := range svgThemes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// cachedCard is a rendered card, as SVG or raster image.
+type cachedCard struct {
+	version uint64
+	data    []byte
+}
+
+// StreamSVG renders the current activity as an SVG card using
+// the named theme. Renders are cached per theme until the
+// activity changes.
+func (c *ActivityClient) StreamSVG(out io.Writer, theme string) error {
+	if theme == "" {
+		theme = DefaultSVGTheme
+	}
+	t, ok := svgThemes[theme]
+	if !ok {
+		return fmt.Errorf("unknown theme %q", theme)
+	}
+
+	c.mu.RLock()
+	activity, version := c.activity, c.version
+	c.mu.RUnlock()
+
+	c.cardMu.Lock()
+	cached, ok := c.cardCache[theme]
+	c.cardMu.Unlock()
+	if ok && cached.version == version {
+		_, err := out.Write(cached.data)
+		return err
+	}
+
+	c.logger.Debug("re-building vscode activity SVG", "theme", theme, "version", version)
+
+	var buf bytes.Buffer
+	if err := renderActivitySVG(&buf, activity, t); err != nil {
+		return err
+	}
+
+	c.cardMu.Lock()
+	c.cardCache[theme] = cachedCard{version: version, data: buf.Bytes()}
+	c.cardMu.Unlock()
+
+	_, err := out.Write(buf.Bytes())
+	return err
+}
+
+type svgSpan struct {
+	Color string
+	Text  string
+}
+
+type svgLine struct {
+	Y     int
+	Spans []svgSpan
+}
+
+func renderActivitySVG(out io.Writer, a EditorActivity, theme SVGTheme) error {
+	tmpl, err := template.ParseFS(templates, "templates/activity.svg")
+	if err != nil {
+		return err
+	}
+
+	colors := map[tokenKind]string{
+		tokenText:    theme.Foreground,
+		tokenKeyword: theme.Keyword,
+		tokenString:  theme.String,
+		tokenComment: theme.Comment,
+		tokenNumber:  theme.Number,
+	}
+
+	chunk := highlightCodeChunk(a)
+	lines := make([]svgLine, len(chunk))
+	for i, l := range chunk {
+		var spans []svgSpan
+		for _, tok := range l {
+			spans = append(spans, svgSpan{
+				Color: colors[tok.Kind],
+				Text:  html.EscapeString(tok.Text),
+			})
+		}
+		lines[i] = svgLine{
+			Y:     svgCodeTop + i*svgLineHeight,
+			Spans: spans,
+		}
+	}
+
+	repository := cardRepository(a)
+	input := struct {
+		Theme      SVGTheme
+		Title      string
+		Filename   string
+		Language   string
+		Repository string
+		Position   string
+		Lines      []svgLine
+		Height     int
+		BoxHeight  int
+		FooterY    int
+	}{
+		Theme:      theme,
+		Title:      html.EscapeString(fmt.Sprintf("Editing %s in %s", a.Filename, repository)),
+		Filename:   html.EscapeString(raster.TruncateRunes(a.Filename, 40)),
+		Language:   html.EscapeString(raster.TruncateRunes(a.Language, 20)),
+		Repository: html.EscapeString(raster.TruncateRunes(repository, 60)),
+		Position:   html.EscapeString(fmt.Sprintf("%s · Ln %d, Col %d", a.Editor, a.Row, a.Col)),
+		Lines:      lines,
+		Height:     svgCardHeight,
+		BoxHeight:  svgCardHeight - 1,
+		FooterY:    svgCardHeight - 10,
+	}
+
+	return tmpl.ExecuteTemplate(out, "activity.svg", input)
+}
+
+// highlightCodeChunk returns the tokens of each line of the
+// code chunk as shown on the card.
+func highlightCodeChunk(a EditorActivity) [][]token {
+	commentPrefix := lineCommentPrefix(a.Language)
+	chunk := codeChunkLines(a.CodeChunk)
+	lines := make([][]token, len(chunk))
+	for i, l := range chunk {
+		lines[i] = highlightLine(l, commentPrefix)
+	}
+	return lines
+}
+
+// cardRepository is the repository shown on the card, falling
+// back to the workspace.
+func cardRepository(a EditorActivity) string {
+	if a.RepositoryURL == "" {
+		return a.Workspace
+	}
+	return a.RepositoryURL
+}
+
+// codeChunkLines prepares a code chunk for display by expanding
+// tabs, trimming surrounding blank lines and common indentation,
+// and clamping it to the card size.
+func codeChunkLines(chunk string) []string {
+	chunk = strings.ReplaceAll(chunk, "\r\n", "\n")
+	chunk = strings.ReplaceAll(chunk, "\t", "    ")
+	lines := strings.Split(chunk, "\n")
+
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > svgMaxLines {
+		lines = lines[:svgMaxLines]
+	}
+
+	indent := -1
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		n := len(l) - len(strings.TrimLeft(l, " "))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+
+	for i, l := range lines {
+		if len(l) >= indent && indent > 0 {
+			l = l[indent:]
+		}
+		lines[i] = raster.TruncateRunes(strings.TrimRight(l, " "), svgMaxLineWidth)
+	}
+
+	assert.Assert(len(lines) <= svgMaxLines, "too many lines")
+	return lines
+}
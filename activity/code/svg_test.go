@@ -0,0 +1,59 @@
+package code
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHighlightLine(t *testing.T) {
+	tokens := highlightLine(`return "a // b" + 42 // done`, "//")
+	assert.Equal(t, []token{
+		{Kind: tokenKeyword, Text: "return"},
+		{Kind: tokenText, Text: " "},
+		{Kind: tokenString, Text: `"a // b"`},
+		{Kind: tokenText, Text: " + "},
+		{Kind: tokenNumber, Text: "42"},
+		{Kind: tokenText, Text: " "},
+		{Kind: tokenComment, Text: "// done"},
+	}, tokens)
+}
+
+func TestCodeChunkLines(t *testing.T) {
+	lines := codeChunkLines("\n\n\t\tif ok {\n\t\t\treturn\n\t\t}\n\n")
+	assert.Equal(t, []string{"if ok {", "    return", "}"}, lines)
+}
+
+func TestStreamSVG(t *testing.T) {
+	client := &ActivityClient{
+		logger:    log.New(io.Discard),
+		cardCache: map[string]cachedCard{},
+		activity: EditorActivity{
+			Filename:  "main.go",
+			Language:  "go",
+			CodeChunk: `fmt.Println("<script>")`,
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, client.StreamSVG(&buf, ""))
+	assert.Contains(t, buf.String(), "&lt;script&gt;")
+	assert.NotContains(t, buf.String(), "<script>")
+
+	// Cached until the activity changes
+	client.activity.Filename = "other.go"
+	buf.Reset()
+	require.NoError(t, client.StreamSVG(&buf, DefaultSVGTheme))
+	assert.Contains(t, buf.String(), "main.go")
+
+	client.version++
+	buf.Reset()
+	require.NoError(t, client.StreamSVG(&buf, DefaultSVGTheme))
+	assert.Contains(t, buf.String(), "other.go")
+
+	assert.Error(t, client.StreamSVG(io.Discard, "neon"))
+}
@@ -0,0 +1,37 @@
+package code
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// OwnerUser is the user all activity belonged to before shigure
+// tracked multiple users. The public, user-less endpoints show
+// the owner's activity.
+const OwnerUser = "owner"
+
+var (
+	ErrInvalidUser = errors.New("invalid user")
+
+	usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+	// Names that would be shadowed by other routes below
+	// /stats/code.
+	reservedUsernames = []string{"calendar", "goals", "leaderboard", "svg"}
+)
+
+// ValidUser reports whether user is a valid username, lower case
+// letters, digits, dashes and underscores of at most 32 characters.
+func ValidUser(user string) bool {
+	return usernamePattern.MatchString(user) && !slices.Contains(reservedUsernames, user)
+}
+
+// RedactionRulesPath is where the redaction rules of user are
+// persisted.
+func RedactionRulesPath(user string) string {
+	if user == OwnerUser {
+		return DefaultRedactionRulesPath
+	}
+	return filepath.Join(filepath.Dir(DefaultRedactionRulesPath), "redactionRules", user+".json")
+}
@@ -0,0 +1,113 @@
+package code
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// WakaTimeHeartbeat is a heartbeat as sent by WakaTime
+// editor plugins and found in WakaTime data exports.
+//
+// See https://wakatime.com/developers#heartbeats
+type WakaTimeHeartbeat struct {
+	Entity   string  `json:"entity"`
+	Type     string  `json:"type"`
+	Category string  `json:"category,omitempty"`
+	Time     float64 `json:"time"`
+	Project  string  `json:"project,omitempty"`
+	Branch   string  `json:"branch,omitempty"`
+	Language string  `json:"language,omitempty"`
+	Lines    uint    `json:"lines,omitempty"`
+	LineNo   uint    `json:"lineno,omitempty"`
+	// CursorPos is the offset of the cursor within the
+	// file, not the column.
+	CursorPos   uint   `json:"cursorpos,omitempty"`
+	IsWrite     bool   `json:"is_write,omitempty"`
+	UserAgentID string `json:"user_agent_id,omitempty"`
+}
+
+// IsFile reports whether the heartbeat is about a file, as
+// opposed to e.g. a visited domain or an app.
+func (h WakaTimeHeartbeat) IsFile() bool {
+	return h.Type == "" || h.Type == "file"
+}
+
+func (h WakaTimeHeartbeat) EditorActivity(editor string) EditorActivity {
+	sec, frac := math.Modf(h.Time)
+	return EditorActivity{
+		Editor:    editor,
+		Workspace: h.Project,
+		Filename:  h.Entity,
+		Language:  h.Language,
+		Row:       h.LineNo,
+		Time:      time.Unix(int64(sec), int64(frac*float64(time.Second))),
+	}
+}
+
+// EditorFromWakaTimeUserAgent extracts the editor from the user
+// agent of the WakaTime CLI, which ends with the editor and the
+// plugin, e.g.
+//
+//	wakatime/v1.73.1 (linux-6.1.0-amd64) go1.20.3 vscode/1.78.2 vscode-wakatime/24.0.10
+func EditorFromWakaTimeUserAgent(userAgent string) string {
+	fields := strings.Fields(userAgent)
+	for i := len(fields) - 1; i >= 0; i-- {
+		name, _, _ := strings.Cut(fields[i], "/")
+		if editor, ok := strings.CutSuffix(name, "-wakatime"); ok && editor != "" {
+			return editor
+		}
+	}
+	return "wakatime"
+}
+
+// WakaTimeExport is the relevant subset of a WakaTime
+// data export of all heartbeats.
+type WakaTimeExport struct {
+	Days []struct {
+		Date       string              `json:"date"`
+		Heartbeats []WakaTimeHeartbeat `json:"heartbeats"`
+	} `json:"days"`
+	UserAgents []struct {
+		ID     string `json:"id"`
+		Value  string `json:"value"`
+		Editor string `json:"editor"`
+	} `json:"user_agents,omitempty"`
+}
+
+// ParseWakaTimeExport reads a WakaTime data export and returns
+// the file heartbeats as editor activity.
+func ParseWakaTimeExport(r io.Reader) ([]EditorActivity, error) {
+	var export WakaTimeExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("decode export: %s", err)
+	}
+
+	editors := make(map[string]string, len(export.UserAgents))
+	for _, ua := range export.UserAgents {
+		editor := ua.Editor
+		if editor == "" {
+			editor = EditorFromWakaTimeUserAgent(ua.Value)
+		}
+		editors[ua.ID] = editor
+	}
+
+	var activities []EditorActivity
+	for _, day := range export.Days {
+		for _, h := range day.Heartbeats {
+			if !h.IsFile() {
+				continue
+			}
+			editor, ok := editors[h.UserAgentID]
+			if !ok {
+				editor = "wakatime"
+			}
+			activities = append(activities, h.EditorActivity(editor))
+		}
+	}
+
+	return activities, nil
+}
@@ -0,0 +1,69 @@
+package code
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditorFromWakaTimeUserAgent(t *testing.T) {
+	tests := map[string]string{
+		"wakatime/v1.73.1 (linux-6.1.0-amd64) go1.20.3 vscode/1.78.2 vscode-wakatime/24.0.10": "vscode",
+		"wakatime/v1.90.0 (darwin-23.0.0-arm64) go1.22.1 neovim/0.9.5 vim-wakatime/11.1.1":    "vim",
+		"wakatime/v1.90.0 (linux) go1.22.1 GoLand/2024.1 GoLand-wakatime/15.0.0":              "GoLand",
+		"curl/8.0.1": "wakatime",
+		"":           "wakatime",
+	}
+	for ua, expected := range tests {
+		assert.Equal(t, expected, EditorFromWakaTimeUserAgent(ua), ua)
+	}
+}
+
+const wakaTimeExport = `{
+	"user": {"username": "tifye"},
+	"days": [
+		{
+			"date": "2024-01-01",
+			"heartbeats": [
+				{"entity": "/home/tifye/shigure/main.go", "type": "file", "time": 1704103200.5, "project": "shigure", "language": "Go", "lineno": 12, "user_agent_id": "ua1"},
+				{"entity": "github.com", "type": "domain", "time": 1704103260, "user_agent_id": "ua1"},
+				{"entity": "C:\\code\\site\\index.ts", "type": "file", "time": 1704103320, "project": "site", "language": "TypeScript"}
+			]
+		}
+	],
+	"user_agents": [
+		{"id": "ua1", "value": "wakatime/v1.73.1 (linux) go1.20.3 vscode/1.78.2 vscode-wakatime/24.0.10"}
+	]
+}`
+
+func TestImportWakaTimeExport(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	activities, err := ParseWakaTimeExport(strings.NewReader(wakaTimeExport))
+	require.NoError(t, err)
+	require.Len(t, activities, 2)
+	assert.Equal(t, "vscode", activities[0].Editor)
+	assert.Equal(t, "wakatime", activities[1].Editor)
+	assert.Equal(t, time.Unix(1704103200, int64(500*time.Millisecond)), activities[0].Time)
+
+	importer, err := NewImporter(store, OwnerUser, filepath.Join(t.TempDir(), "rules.json"), filepath.Join(t.TempDir(), "aliases.json"))
+	require.NoError(t, err)
+
+	inserted, err := importer.Import(ctx, activities)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), inserted)
+
+	inserted, err = importer.Import(ctx, activities)
+	require.NoError(t, err)
+	assert.Zero(t, inserted, "expected already imported heartbeats to be skipped")
+
+	var filenames []string
+	require.NoError(t, store.db.SelectContext(ctx, &filenames, `select "filename" from code_activity order by reported_at`))
+	assert.Equal(t, []string{"main.go", "index.ts"}, filenames)
+}
@@ -3,12 +3,14 @@ package youtube
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
+	"path/filepath"
+	"time"
 
-	"github.com/charmbracelet/log"
+	"github.com/tifye/shigure/assert"
 )
 
 type YoutubeVideoListResponse struct {
@@ -24,62 +26,248 @@ type YoutubeVideoResource struct {
 			HighRes   YoutubeThumbnailData `json:"high"`
 			MediumRes YoutubeThumbnailData `json:"medium"`
 		} `json:"thumbnails"`
+		// LiveBroadcastContent is live or upcoming for live
+		// streams and premieres, and none otherwise.
+		LiveBroadcastContent string `json:"liveBroadcastContent"`
 	} `json:"snippet"`
 	ContentDetails struct {
 		Duration string `json:"duration"`
 	} `json:"contentDetails"`
 }
 
+// isLive reports whether the video is a live stream or premiere.
+// Streams are P0D while live, premieres have the duration of the
+// video but are watched live all the same.
+func (r YoutubeVideoResource) isLive() bool {
+	return r.ContentDetails.Duration == "P0D" ||
+		r.Snippet.LiveBroadcastContent == "live" ||
+		r.Snippet.LiveBroadcastContent == "upcoming"
+}
+
 type YoutubeThumbnailData struct {
 	Url    string `json:"url"`
 	Width  uint   `json:"width"`
 	Height uint   `json:"height"`
 }
 
-func FetchYoutubeVideoResource(
-	ctx context.Context,
-	logger *log.Logger,
-	apiKey string,
-	videoId string,
-) (YoutubeVideoResource, error) {
-	url, err := url.Parse("https://youtube.googleapis.com/youtube/v3/videos")
+const (
+	youtubeAPIURL = "https://youtube.googleapis.com/youtube/v3"
+
+	DefaultYoutubeCacheDir = "./data/youtube"
+)
+
+// YoutubeProvider resolves video ids using the YouTube Data API.
+// Video resources are cached and the quota spent on fetching
+// them is tracked, once the daily quota runs out videos are
+// resolved by the fallback provider instead.
+type YoutubeProvider struct {
+	apiKey   string
+	baseURL  string
+	client   *http.Client
+	cache    *videoCache
+	quota    *quotaTracker
+	fallback MediaProvider
+}
+
+type YoutubeProviderOptions struct {
+	// CacheDir is where video resources and the quota used are
+	// kept, DefaultYoutubeCacheDir if empty.
+	CacheDir string
+	// CacheSize is how many video resources are kept in memory,
+	// DefaultVideoCacheSize if zero.
+	CacheSize int
+	// QuotaLimit is the daily quota units the provider may spend,
+	// DefaultYoutubeQuotaLimit if zero.
+	QuotaLimit int
+	// Fallback resolves the watch URLs of videos while the quota
+	// is exhausted, usually an OEmbedProvider. Optional.
+	Fallback MediaProvider
+}
+
+// YoutubeProviderStatus is the state of the quota and cache of
+// a YoutubeProvider.
+type YoutubeProviderStatus struct {
+	Quota QuotaUsage      `json:"quota"`
+	Cache VideoCacheStats `json:"cache"`
+	// Degraded is set while videos are resolved by the fallback.
+	Degraded bool `json:"degraded"`
+}
+
+func NewYoutubeProvider(apiKey string, opts YoutubeProviderOptions) (*YoutubeProvider, error) {
+	assert.AssertNotEmpty(apiKey)
+
+	if opts.CacheDir == "" {
+		opts.CacheDir = DefaultYoutubeCacheDir
+	}
+	if opts.CacheSize == 0 {
+		opts.CacheSize = DefaultVideoCacheSize
+	}
+	if opts.QuotaLimit == 0 {
+		opts.QuotaLimit = DefaultYoutubeQuotaLimit
+	}
+
+	cache, err := newVideoCache(filepath.Join(opts.CacheDir, "videos"), opts.CacheSize)
 	if err != nil {
-		return YoutubeVideoResource{}, err
+		return nil, fmt.Errorf("video cache: %s", err)
 	}
-	query := url.Query()
-	query.Add("part", "snippet,contentDetails")
-	query.Add("id", videoId)
-	query.Add("key", apiKey)
-	url.RawQuery = query.Encode()
-	res, err := http.Get(url.String())
+	quota, err := newQuotaTracker(filepath.Join(opts.CacheDir, "quota.json"), opts.QuotaLimit)
 	if err != nil {
-		return YoutubeVideoResource{}, err
+		return nil, fmt.Errorf("quota tracker: %s", err)
 	}
 
-	body, err := io.ReadAll(res.Body)
+	return &YoutubeProvider{
+		apiKey:   apiKey,
+		baseURL:  youtubeAPIURL,
+		client:   providerClient,
+		cache:    cache,
+		quota:    quota,
+		fallback: opts.Fallback,
+	}, nil
+}
+
+func (p *YoutubeProvider) Name() string {
+	return ProviderYoutube
+}
+
+func (p *YoutubeProvider) Status() YoutubeProviderStatus {
+	usage := p.quota.usage(time.Now())
+	return YoutubeProviderStatus{
+		Quota:    usage,
+		Cache:    p.cache.stats(),
+		Degraded: usage.Remaining() < videosListQuotaCost,
+	}
+}
+
+// Resolve marks live streams and premieres as live, which
+// have no duration.
+func (p *YoutubeProvider) Resolve(ctx context.Context, videoId string) (Activity, error) {
+	resource, err := p.FetchVideoResource(ctx, videoId)
+	if errors.Is(err, ErrQuotaExhausted) && p.fallback != nil {
+		return p.resolveFallback(ctx, videoId)
+	}
+	if err != nil {
+		return Activity{}, fmt.Errorf("fetch video: %w", err)
+	}
+
+	duration, err := ParseISO8601Duration(resource.ContentDetails.Duration)
 	if err != nil {
+		return Activity{}, fmt.Errorf("%w: duration of video %s: %s", ErrProviderUnavailable, resource.Id, err)
+	}
+	live := resource.isLive()
+	if live {
+		duration = 0
+	}
+
+	return Activity{
+		Provider:     ProviderYoutube,
+		Id:           resource.Id,
+		Url:          youtubeWatchURL(resource.Id),
+		Title:        resource.Snippet.Title,
+		Author:       resource.Snippet.ChannelTitle,
+		ThumbnailUrl: resource.Snippet.Thumbnails.HighRes.Url,
+		Duration:     duration,
+		Live:         live,
+	}, nil
+}
+
+// resolveFallback resolves the watch URL of the video with the
+// fallback, which knows nothing of its duration.
+func (p *YoutubeProvider) resolveFallback(ctx context.Context, videoId string) (Activity, error) {
+	watchURL := youtubeWatchURL(videoId)
+	a, err := p.fallback.Resolve(ctx, watchURL)
+	if err != nil {
+		return Activity{}, fmt.Errorf("fallback %s: %w", p.fallback.Name(), err)
+	}
+	a.Provider = ProviderYoutube
+	a.Id = videoId
+	a.Url = watchURL
+	return a, nil
+}
+
+func youtubeWatchURL(videoId string) string {
+	return fmt.Sprintf("https://www.youtube.com/watch?v=%s", url.QueryEscape(videoId))
+}
+
+// FetchVideoResource returns the cached resource of the video,
+// fetching it if the quota of the day allows.
+func (p *YoutubeProvider) FetchVideoResource(ctx context.Context, videoId string) (YoutubeVideoResource, error) {
+	now := time.Now()
+	if resource, ok := p.cache.get(now, videoId); ok {
+		return resource, nil
+	}
+
+	if err := p.quota.reserve(now, videosListQuotaCost); err != nil {
 		return YoutubeVideoResource{}, err
 	}
-	defer func() {
-		err = res.Body.Close()
-		if err != nil {
-			logger.Error("body close: %s", err)
+
+	resource, err := p.fetchVideoResource(ctx, videoId)
+	var respErr *responseError
+	if errors.As(err, &respErr) && isQuotaExceededResponse(respErr) {
+		if err := p.quota.exhaust(now); err != nil {
+			return YoutubeVideoResource{}, fmt.Errorf("persist quota: %s", err)
 		}
-	}()
+		return YoutubeVideoResource{}, fmt.Errorf("%w: %s", ErrQuotaExhausted, err)
+	}
+	if err != nil {
+		return YoutubeVideoResource{}, err
+	}
+
+	// Live streams become videos once they end. Failing to
+	// cache only costs quota later on.
+	if !resource.isLive() {
+		_ = p.cache.put(now, resource)
+	}
+	return resource, nil
+}
 
-	if res.StatusCode > 299 {
-		return YoutubeVideoResource{}, fmt.Errorf("response failed with code %d\nand body: %s", res.StatusCode, body)
+func (p *YoutubeProvider) fetchVideoResource(ctx context.Context, videoId string) (YoutubeVideoResource, error) {
+	url, err := url.Parse(p.baseURL + "/videos")
+	if err != nil {
+		return YoutubeVideoResource{}, err
 	}
+	query := url.Query()
+	query.Add("part", "snippet,contentDetails")
+	query.Add("id", videoId)
+	url.RawQuery = query.Encode()
+
+	// In a header rather than the query so that it does not end
+	// up in logged URLs
+	header := http.Header{}
+	header.Set("X-Goog-Api-Key", p.apiKey)
 
 	var resp YoutubeVideoListResponse
-	err = json.Unmarshal(body, &resp)
-	if err != nil {
+	if err := getJSON(ctx, p.client, url.String(), header, &resp); err != nil {
 		return YoutubeVideoResource{}, err
 	}
 
 	if len(resp.Items) <= 0 {
-		return YoutubeVideoResource{}, fmt.Errorf("could not find video resource for %s", videoId)
+		return YoutubeVideoResource{}, fmt.Errorf("%w: could not find video resource for %s", ErrMediaNotFound, videoId)
 	}
 
 	return resp.Items[0], nil
 }
+
+// isQuotaExceededResponse reports whether the API refused the
+// request for lack of quota, see
+// https://developers.google.com/youtube/v3/docs/errors
+func isQuotaExceededResponse(e *responseError) bool {
+	if e.StatusCode != http.StatusForbidden {
+		return false
+	}
+	var body struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(e.Body, &body); err != nil {
+		return false
+	}
+	for _, e := range body.Error.Errors {
+		if e.Reason == "quotaExceeded" || e.Reason == "dailyLimitExceeded" {
+			return true
+		}
+	}
+	return false
+}
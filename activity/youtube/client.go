@@ -2,69 +2,125 @@ package youtube
 
 import (
 	"context"
-	"embed"
-	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"html"
-	"io"
-	"net/http"
-	"os"
 	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
-	"text/template"
 	"time"
 
 	"github.com/charmbracelet/log"
 
 	"github.com/tifye/shigure/assert"
+	"github.com/tifye/shigure/mux"
 )
 
-//go:embed templates/*
-var templates embed.FS
-
+// Activity is the media currently being watched or listened to.
 type Activity struct {
+	// Provider is the name of the MediaProvider the
+	// activity was resolved by.
+	Provider     string
 	Id           string
 	Title        string
 	Author       string
 	Url          string
 	ThumbnailUrl string
-	Duration     time.Duration
+	// Duration is 0 for live media.
+	Duration time.Duration
+	// Live is set for live streams and premieres, which are
+	// kept as the activity while the player reports on them
+	// rather than until their end.
+	Live bool
+
+	// Position, Rate and Paused are the playback estimated
+	// from the last state reported by the player, or from
+	// when the activity was set if none was reported.
+	Position time.Duration
+	Rate     float64
+	Paused   bool
+	// Progress is Position as a fraction of Duration.
+	Progress float64
 }
 
+// placeholderVideoID identifies the activity shown when
+// nothing is being watched.
+const placeholderVideoID = "Chocola X Vanilla"
+
 type ActivityClient struct {
-	apiKey string
-	logger *log.Logger
+	logger    *log.Logger
+	store     *YoutubeActivityStore
+	providers map[string]MediaProvider
+
+	mux            *mux.Mux
+	muxMessageType string
 
 	lastUpdate      atomic.Value
 	currentActivity Activity
-	dirty           atomic.Bool
-	mu              sync.RWMutex
-	fileMu          sync.Mutex
+	playback        playback
+	// version is incremented whenever the activity or its
+	// playback changes, invalidating rendered SVGs.
+	version    uint64
+	modifiedAt time.Time
+	mu         sync.RWMutex
+
+	svgTemplateDir string
+	cardCache      map[string]cachedCard
+	thumbnails     *ThumbnailCache
+	cardMu         sync.Mutex
 }
 
-func NewClient(logger *log.Logger, apiKey string) *ActivityClient {
-	assert.AssertNotNil(logger)
-	assert.AssertNotEmpty(apiKey)
+type ActivityClientOptions struct {
+	// Providers resolve media ids into activity, each must
+	// have a unique name.
+	Providers []MediaProvider
+	// SVGTemplateDir is an optional directory of *.svg card
+	// templates adding to, or replacing, the built-in themes.
+	SVGTemplateDir string
+	Thumbnails     *ThumbnailCache
+}
 
-	err := os.MkdirAll("data", 0644)
-	if err != nil {
-		panic(err)
+func NewClient(logger *log.Logger, store *YoutubeActivityStore, mux *mux.Mux, opts ActivityClientOptions) *ActivityClient {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(store)
+	assert.AssertNotNil(mux)
+	assert.AssertNotNil(opts.Thumbnails)
+
+	providersByName := make(map[string]MediaProvider, len(opts.Providers))
+	for _, p := range opts.Providers {
+		_, exists := providersByName[p.Name()]
+		assert.Assert(!exists, "provider already registered with this name")
+		providersByName[p.Name()] = p
 	}
 
 	client := &ActivityClient{
 		logger:     logger,
-		apiKey:     apiKey,
+		store:      store,
+		providers:  providersByName,
 		lastUpdate: atomic.Value{},
+
+		svgTemplateDir: opts.SVGTemplateDir,
+		thumbnails:     opts.Thumbnails,
+		cardCache:      map[string]cachedCard{},
+
+		mux:            mux,
+		muxMessageType: "youtube",
 	}
 	client.lastUpdate.Store(time.Now())
 
+	// Nothing is being watched yet, videos that were when the
+	// previous process stopped would otherwise count forever
+	ended, err := store.EndOpen(context.Background(), time.Now())
+	if err != nil {
+		logger.Error("end videos left open", "err", err)
+	} else if ended > 0 {
+		logger.Info("ended videos left open", "videos", ended)
+	}
+
 	ticker := time.NewTicker(30 * time.Second)
 	go func() {
 		for range ticker.C {
-			a := client.Activity()
-			if time.Since(client.lastUpdate.Load().(time.Time)) >= a.Duration {
+			if time.Now().After(client.expectedEnd()) {
 				client.ClearActivity()
 			}
 		}
@@ -74,200 +130,242 @@ func NewClient(logger *log.Logger, apiKey string) *ActivityClient {
 	return client
 }
 
-func (c *ActivityClient) SetYoutubeActivity(ctx context.Context, videoId string) error {
-	assert.AssertNotEmpty(videoId)
+func (c *ActivityClient) MessageType() string {
+	return c.muxMessageType
+}
 
-	if c.Activity().Id == videoId {
-		return nil
-	}
+func (c *ActivityClient) HandleMessage(_ *mux.Channel, _ []byte) error {
+	return nil
+}
 
-	resource, err := FetchYoutubeVideoResource(ctx, c.logger, c.apiKey, videoId)
-	if err != nil {
-		return fmt.Errorf("fetch video: %s", err)
-	}
+// activityMessage is the broadcast payload, Watching is false
+// while the placeholder is shown.
+type activityMessage struct {
+	Activity
+	Watching bool `json:"watching"`
+}
 
-	if !isYoutubeVideoDurationLessThan1Day(resource.ContentDetails.Duration) {
-		c.logger.Info("skipping video 24h+ duration video", "videoId", resource.Id, "title", resource.Snippet.Title)
-		return nil
+func (c *ActivityClient) activityMessage() ([]byte, error) {
+	a := c.Activity()
+	return json.Marshal(activityMessage{Activity: a, Watching: a.Id != placeholderVideoID})
+}
+
+// HandleSubscription sends the current activity to channels
+// subscribing to youtube activity.
+func (c *ActivityClient) HandleSubscription(ch *mux.Channel, typ mux.MessageType, didSub bool) {
+	if !didSub {
+		return
 	}
 
-	c.setActivity(Activity{
-		Id:           resource.Id,
-		Url:          fmt.Sprintf("https://www.youtube.com/watch?v=%s", resource.Id),
-		Title:        resource.Snippet.Title,
-		Author:       resource.Snippet.ChannelTitle,
-		ThumbnailUrl: resource.Snippet.Thumbnails.HighRes.Url,
-		Duration:     parseYoutubeVideoDuration(resource.ContentDetails.Duration),
+	msg, err := c.activityMessage()
+	if err != nil {
+		c.logger.Error("marshal youtube activity", "err", err)
+		return
+	}
+	err = c.mux.SendSession(ch.Session().ID(), typ, msg, func(other *mux.Channel) bool {
+		return other.ID() != ch.ID()
 	})
+	if err != nil {
+		c.logger.Error("send youtube activity", "err", err)
+	}
+}
 
-	return nil
+func (c *ActivityClient) broadcast() {
+	msg, err := c.activityMessage()
+	if err != nil {
+		c.logger.Error("marshal youtube activity", "err", err)
+		return
+	}
+	if err := c.mux.Broadcast(c.muxMessageType, msg, nil); err != nil {
+		c.logger.Error("broadcast youtube activity", "err", err)
+	}
 }
 
-// Returned true/false whether the duration if less
-// than 1 day. Duration format follows ISO_8601.
-//
-// See https://en.wikipedia.org/wiki/ISO_8601#Durations
-func isYoutubeVideoDurationLessThan1Day(duration string) bool {
-	return strings.HasPrefix(duration, "PT")
+func (c *ActivityClient) SetYoutubeActivity(ctx context.Context, videoId string) error {
+	return c.SetMediaActivity(ctx, ProviderYoutube, videoId)
 }
 
-// Converts an ISO_8601 duration into a time.Duration.
-// Only accepts durations less than 1 day.
-//
-// See https://en.wikipedia.org/wiki/ISO_8601#Durations
-func parseYoutubeVideoDuration(duration string) time.Duration {
-	// PT##H##M##S
-	assert.Assert(len(duration) <= 11, fmt.Sprintf("invalid duration string: %s", duration))
-	// PT+#T
-	assert.Assert(len(duration) >= 4, fmt.Sprintf("invalid duration string: %s", duration))
-	assert.Assert(strings.HasPrefix(duration, "PT"), "expected duration string to have 'PT' ISO_8601 prefix")
-
-	duration = strings.TrimPrefix(duration, "PT")
-	var (
-		hourPart    string
-		minutesPart string
-		secondsPart string
-	)
-	start := 0
-	for pos := range len(duration) {
-		switch duration[pos] {
-		case 'H':
-			hourPart = duration[start:pos]
-			start = pos + 1
-		case 'M':
-			minutesPart = duration[start:pos]
-			start = pos + 1
-		case 'S':
-			secondsPart = duration[start:pos]
-			start = pos + 1
-		default:
-			continue
+// SetMediaActivity resolves id using the named provider and
+// makes it the current activity.
+func (c *ActivityClient) SetMediaActivity(ctx context.Context, provider string, id string) error {
+	assert.AssertNotEmpty(id)
+
+	p, ok := c.providers[provider]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+
+	current := c.Activity()
+	if current.Provider == provider && current.Id == mediaID(id) {
+		if current.Live {
+			c.keepLive()
 		}
+		return nil
 	}
 
-	assert.AssertNotEmpty(hourPart + minutesPart + secondsPart)
+	a, err := p.Resolve(ctx, id)
+	if errors.Is(err, ErrSkipMedia) {
+		c.logger.Info("skipping media", "provider", provider, "id", id, "reason", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("resolve %s media: %w", provider, err)
+	}
 
-	hours, _ := strconv.Atoi(hourPart)
-	minutes, _ := strconv.Atoi(minutesPart)
-	seconds, _ := strconv.Atoi(secondsPart)
+	c.SetActivity(ctx, a)
+	return nil
+}
 
-	return time.Duration(time.Hour*time.Duration(hours) + time.Minute*time.Duration(minutes) + time.Second*time.Duration(seconds))
+// SetActivity makes a the current activity, for media that is
+// reported as is rather than resolved by a provider.
+func (c *ActivityClient) SetActivity(ctx context.Context, a Activity) {
+	if a.Duration <= 0 && !a.Live {
+		a.Duration = DefaultMediaDuration
+	}
+	c.mu.RLock()
+	current := c.currentActivity
+	c.mu.RUnlock()
+	if current == a {
+		return
+	}
+	c.setActivity(ctx, a)
 }
 
 func (c *ActivityClient) Activity() Activity {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.currentActivity
+	return c.activityAt(time.Now())
 }
 
-func (c *ActivityClient) setActivity(a Activity) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.currentActivity = a
-	c.lastUpdate.Store(time.Now())
-	c.dirty.Store(true)
+// activityAt returns the current activity with the playback
+// estimated at now, must be called with mu held.
+func (c *ActivityClient) activityAt(now time.Time) Activity {
+	a := c.currentActivity
+	a.Position = c.playback.position(now)
+	a.Rate = c.playback.Rate
+	a.Paused = c.playback.Paused
+	a.Progress = c.playback.progress(now)
+	return a
 }
 
-func (c *ActivityClient) ClearActivity() {
-	if c.Activity().Id == "Chocola X Vanilla" {
-		return
-	}
-
-	c.logger.Info("clearing activity")
-	c.setActivity(Activity{
-		Id:           "Chocola X Vanilla",
-		Title:        "(─‿‿─)",
-		Author:       "ヾ( ￣O￣)ツ",
-		Url:          "https://www.joshuadematas.me/",
-		ThumbnailUrl: "https://i.pinimg.com/736x/71/eb/50/71eb502aea2fc4e816b67a5bbd114d27.jpg",
-		Duration:     time.Duration(55 * time.Minute),
-	})
-	c.dirty.Store(true)
-}
+// Version identifies the current activity and when it last
+// changed, for use as HTTP cache validators. While playing it
+// also changes as often as cards are re-rendered for progress.
+func (c *ActivityClient) Version() (string, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-func (c *ActivityClient) StreamSVG(ctx context.Context, out io.Writer) error {
-	if !c.dirty.Load() {
-		file, err := os.Open("data/activity.svg")
-		if err != nil {
-			return err
-		}
-		_, err = io.Copy(out, file)
-		return err
+	if c.currentActivity.Id == placeholderVideoID || c.playback.Paused || c.playback.live {
+		return strconv.FormatUint(c.version, 10), c.modifiedAt
 	}
+	epoch := time.Since(c.playback.reportedAt) / svgProgressInterval
+	return fmt.Sprintf("%d.%d", c.version, epoch), c.playback.reportedAt.Add(epoch * svgProgressInterval)
+}
 
-	c.logger.Info("activity dirty, re-building SVG")
-
-	c.fileMu.Lock()
-	defer c.fileMu.Unlock()
-
-	file, err := os.OpenFile("data/activity.svg", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+func (c *ActivityClient) expectedEnd() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.playback.expectedEnd()
+}
 
-	out = io.MultiWriter(file, out)
+// keepLive extends the live activity as if the player reported
+// playback as estimated, for players that only report the media.
+func (c *ActivityClient) keepLive() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.playback.Position = c.playback.position(now)
+	c.playback.reportedAt = now
+}
 
-	templates, err := template.ParseFS(templates, "*/**")
-	if err != nil {
+// SetPlayback updates the playback of the current activity as
+// reported by the player. When id is not empty it must be that
+// of the current activity, guarding against late reports of
+// media that was already replaced.
+func (c *ActivityClient) SetPlayback(id string, state PlaybackState) error {
+	if err := state.Validate(); err != nil {
 		return err
 	}
 
-	activity := c.Activity()
-	c.logger.Debug("downloading activity thumb", "id", activity.Id, "url", activity.ThumbnailUrl)
-	base64Image, err := downloadBase64Image(ctx, activity.ThumbnailUrl)
-	if err != nil {
-		return fmt.Errorf("image download: %s", err)
+	c.mu.Lock()
+	if id != "" && mediaID(id) != c.currentActivity.Id {
+		c.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrPlaybackMismatch, id)
 	}
-
-	title := fmt.Sprintf("%s - %s", activity.Title, activity.Author)
-	input := struct {
-		Title        string
-		Base64Image  string
-		ExternalLink string
-	}{
-		Title:        html.EscapeString(title),
-		Base64Image:  base64Image,
-		ExternalLink: html.EscapeString(activity.Url),
+	if !c.currentActivity.Live {
+		state.Position = min(state.Position, c.currentActivity.Duration)
 	}
-
-	err = templates.ExecuteTemplate(out, ".template.svg", input)
-	if err != nil {
-		return err
+	c.playback = playback{
+		PlaybackState: state,
+		reportedAt:    time.Now(),
+		duration:      c.currentActivity.Duration,
+		live:          c.currentActivity.Live,
 	}
+	c.version++
+	c.modifiedAt = c.playback.reportedAt
+	c.mu.Unlock()
 
-	c.dirty.Store(false)
+	c.broadcast()
 	return nil
 }
 
-func downloadBase64Image(ctx context.Context, url string) (string, error) {
-	type result struct {
-		img string
-		err error
+// setActivity replaces the current activity and records the
+// previous video as watched until now.
+func (c *ActivityClient) setActivity(ctx context.Context, a Activity) {
+	// Truncated to the precision of DuckDB timestamps so that
+	// the watched video can be matched when it ends
+	now := time.Now().Truncate(time.Microsecond)
+
+	c.mu.Lock()
+	prev := c.currentActivity
+	prevStart := c.lastUpdate.Load()
+	c.currentActivity = a
+	c.playback = playback{
+		PlaybackState: PlaybackState{Rate: 1},
+		reportedAt:    now,
+		duration:      a.Duration,
+		live:          a.Live,
 	}
-	resch := make(chan result)
-	go func() {
-		response, err := http.Get(url)
-		if err != nil {
-			resch <- result{err: err}
-			return
-		}
-		defer response.Body.Close()
+	c.lastUpdate.Store(now)
+	c.version++
+	c.modifiedAt = c.playback.reportedAt
+	c.mu.Unlock()
 
-		bytes, err := io.ReadAll(response.Body)
+	c.broadcast()
+
+	if prev.Id != "" && prev.Id != placeholderVideoID {
+		if err := c.store.End(ctx, prev.Id, prevStart.(time.Time), now); err != nil {
+			c.logger.Error("end watched video", "err", err, "videoId", prev.Id)
+		}
+	}
+	if a.Id != placeholderVideoID {
+		err := c.store.Insert(ctx, WatchedVideo{
+			Provider:        a.Provider,
+			VideoID:         a.Id,
+			Title:           a.Title,
+			Channel:         a.Author,
+			Url:             a.Url,
+			DurationSeconds: uint(a.Duration.Seconds()),
+			StartedAt:       now,
+		})
 		if err != nil {
-			resch <- result{err: err}
-			return
+			c.logger.Error("insert watched video", "err", err, "videoId", a.Id)
 		}
+	}
+}
 
-		str := base64.StdEncoding.EncodeToString(bytes)
-		resch <- result{img: fmt.Sprintf("data:image/jpeg;base64,%s", str)}
-	}()
-
-	select {
-	case res := <-resch:
-		return res.img, res.err
-	case <-ctx.Done():
-		return "", ctx.Err()
+func (c *ActivityClient) ClearActivity() {
+	if c.Activity().Id == placeholderVideoID {
+		return
 	}
+
+	c.logger.Info("clearing activity")
+	c.setActivity(context.Background(), Activity{
+		Id:           placeholderVideoID,
+		Title:        "(─‿‿─)",
+		Author:       "ヾ( ￣O￣)ツ",
+		Url:          "https://www.joshuadematas.me/",
+		ThumbnailUrl: "https://i.pinimg.com/736x/71/eb/50/71eb502aea2fc4e816b67a5bbd114d27.jpg",
+		Duration:     time.Duration(55 * time.Minute),
+	})
 }
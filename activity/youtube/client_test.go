@@ -1,39 +1,46 @@
 package youtube
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"testing"
 	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tifye/shigure/mux"
 )
 
-func TestParseISO8601Duration(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected time.Duration
-	}{
-		{
-			input:    "PT60M20S",
-			expected: time.Duration(time.Minute*60 + time.Second*20),
-		},
-		{
-			input:    "PT24H20S",
-			expected: time.Duration(time.Hour*24 + time.Second*20),
-		},
-		{
-			input:    "PT20S",
-			expected: time.Duration(time.Second * 20),
-		},
-		{
-			input:    "PT60M",
-			expected: time.Duration(time.Minute * 60),
-		},
+func TestSetActivityBroadcast(t *testing.T) {
+	ctx := context.Background()
+	m := mux.NewMux(log.New(io.Discard))
+	client := &ActivityClient{
+		logger:         log.New(io.Discard),
+		store:          newTestStore(t),
+		mux:            m,
+		muxMessageType: "youtube",
 	}
+	client.lastUpdate.Store(time.Now())
+	m.RegisterHandler(client.MessageType(), client)
 
-	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			dur := parseYoutubeVideoDuration(tt.input)
-			assert.Equal(t, tt.expected, dur)
-		})
+	var out bytes.Buffer
+	sessionID := mux.ID{1}
+	channelID := m.Connect(sessionID, &out)
+	require.NoError(t, m.Message(sessionID, channelID, []byte(`{"type":"mux:subscribe","payload":{"MessageType":"youtube"}}`)))
+
+	client.setActivity(ctx, Activity{Id: "dQw4w9WgXcQ", Author: "Rick Astley", Duration: 3 * time.Minute})
+	assert.Contains(t, out.String(), `"Id":"dQw4w9WgXcQ"`)
+	assert.Contains(t, out.String(), `"watching":true`)
+
+	out.Reset()
+	client.ClearActivity()
+	assert.Contains(t, out.String(), `"watching":false`)
+
+	watches, err := client.store.RecentWatches(ctx, StatsFilter{}, time.Now(), 10)
+	require.NoError(t, err)
+	if assert.Len(t, watches, 1) {
+		assert.Equal(t, "Rick Astley", watches[0].Channel)
 	}
 }
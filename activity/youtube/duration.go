@@ -0,0 +1,148 @@
+package youtube
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidDuration = errors.New("invalid ISO 8601 duration")
+
+// Nominal lengths of the calendar units of durations, their
+// actual lengths depend on the date they are counted from.
+const (
+	durationDay   = 24 * time.Hour
+	durationWeek  = 7 * durationDay
+	durationMonth = 30 * durationDay
+	durationYear  = 365 * durationDay
+)
+
+type durationUnit struct {
+	designator byte
+	length     time.Duration
+}
+
+var (
+	dateDurationUnits = []durationUnit{{'Y', durationYear}, {'M', durationMonth}, {'W', durationWeek}, {'D', durationDay}}
+	timeDurationUnits = []durationUnit{{'H', time.Hour}, {'M', time.Minute}, {'S', time.Second}}
+)
+
+// ParseISO8601Duration parses durations of the form
+// PnYnMnWnDTnHnMnS, such as PT4M13S, where components may be
+// left out but not reordered. The last component may have a
+// fraction, e.g. PT1.5S. Years, months and weeks are taken to
+// be 365, 30 and 7 days.
+//
+// See https://en.wikipedia.org/wiki/ISO_8601#Durations
+func ParseISO8601Duration(s string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(s, "P")
+	if !ok {
+		return 0, fmt.Errorf("%w: %q does not start with P", ErrInvalidDuration, s)
+	}
+	datePart, timePart, hasTime := strings.Cut(rest, "T")
+	if datePart == "" && timePart == "" {
+		return 0, fmt.Errorf("%w: %q has no components", ErrInvalidDuration, s)
+	}
+	if hasTime && timePart == "" {
+		return 0, fmt.Errorf("%w: %q has no components after T", ErrInvalidDuration, s)
+	}
+
+	var (
+		total      time.Duration
+		fractional bool
+	)
+	parse := func(part string, units []durationUnit) error {
+		// Units must come in order, from the largest
+		next := 0
+		for part != "" {
+			if fractional {
+				return fmt.Errorf("%w: %q has a fraction before its last component", ErrInvalidDuration, s)
+			}
+
+			end := strings.IndexFunc(part, func(r rune) bool {
+				return (r < '0' || r > '9') && r != '.' && r != ','
+			})
+			if end < 0 {
+				return fmt.Errorf("%w: %q ends without a designator", ErrInvalidDuration, s)
+			}
+			number, designator := part[:end], part[end]
+			part = part[end+1:]
+
+			i := -1
+			for j, u := range units[next:] {
+				if u.designator == designator {
+					i = next + j
+					break
+				}
+			}
+			if i < 0 {
+				return fmt.Errorf("%w: unexpected %q in %q", ErrInvalidDuration, designator, s)
+			}
+			next = i + 1
+
+			d, frac, err := parseDurationComponent(number, units[i].length)
+			if err != nil {
+				return fmt.Errorf("%w: %q: %s", ErrInvalidDuration, s, err)
+			}
+			if total > math.MaxInt64-d {
+				return fmt.Errorf("%w: %q overflows", ErrInvalidDuration, s)
+			}
+			total += d
+			fractional = frac
+		}
+		return nil
+	}
+
+	if err := parse(datePart, dateDurationUnits); err != nil {
+		return 0, err
+	}
+	if err := parse(timePart, timeDurationUnits); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// parseDurationComponent parses the number of a component, such
+// as 1 or 1.5, as that many of unit. Fractions are kept to the
+// precision of nanoseconds.
+func parseDurationComponent(number string, unit time.Duration) (d time.Duration, fractional bool, err error) {
+	intPart, fracPart, fractional := strings.Cut(strings.Replace(number, ",", ".", 1), ".")
+	if !isDigits(intPart) || (fractional && !isDigits(fracPart)) {
+		return 0, false, fmt.Errorf("invalid number %q", number)
+	}
+
+	n, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || n > math.MaxInt64/int64(unit) {
+		return 0, false, fmt.Errorf("%s overflows", number)
+	}
+	d = time.Duration(n) * unit
+
+	if fractional {
+		const digits = 9
+		fracPart = (fracPart + strings.Repeat("0", digits))[:digits]
+		// Units are whole seconds so this is exact and can't
+		// overflow before the sum is checked
+		nanos, _ := strconv.ParseInt(fracPart, 10, 64)
+		f := time.Duration(nanos) * (unit / time.Second)
+		if d > math.MaxInt64-f {
+			return 0, false, fmt.Errorf("%s overflows", number)
+		}
+		d += f
+	}
+	return d, fractional, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
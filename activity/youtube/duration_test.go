@@ -0,0 +1,136 @@
+package youtube
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseISO8601Duration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{
+			input:    "PT60M20S",
+			expected: time.Duration(time.Minute*60 + time.Second*20),
+		},
+		{
+			input:    "PT24H20S",
+			expected: time.Duration(time.Hour*24 + time.Second*20),
+		},
+		{
+			input:    "PT20S",
+			expected: time.Duration(time.Second * 20),
+		},
+		{
+			input:    "PT60M",
+			expected: time.Duration(time.Minute * 60),
+		},
+		{
+			input:    "P0D",
+			expected: 0,
+		},
+		{
+			input:    "P1DT2H3M4S",
+			expected: 26*time.Hour + 3*time.Minute + 4*time.Second,
+		},
+		{
+			input:    "P1Y2M3W4D",
+			expected: 365*durationDay + 60*durationDay + 25*durationDay,
+		},
+		{
+			input:    "PT1.5S",
+			expected: 1500 * time.Millisecond,
+		},
+		{
+			input:    "PT0,25H",
+			expected: 15 * time.Minute,
+		},
+		{
+			input:    "PT1M0.000000001S",
+			expected: time.Minute + time.Nanosecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			dur, err := ParseISO8601Duration(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, dur)
+		})
+	}
+}
+
+func TestParseISO8601DurationInvalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"P",
+		"PT",
+		"T1H",
+		"1H",
+		"pt1h",
+		"PT1H1H",
+		"PT1S1M",
+		"P1D1Y",
+		"P1H",
+		"PT1D",
+		"PT1.5M1S",
+		"PT.5S",
+		"PT1.S",
+		"PT1.2.3S",
+		"PT-1S",
+		"PT+1S",
+		"PT1",
+		"PTS",
+		"P1DT",
+		"PT1HT1M",
+		"PT9999999999999999999H",
+		"PT2562048H",
+		"P106751DT23H47M16.854775808S",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseISO8601Duration(input)
+			assert.ErrorIs(t, err, ErrInvalidDuration)
+		})
+	}
+}
+
+// formatISO8601Duration formats d in hours, minutes and seconds.
+func formatISO8601Duration(d time.Duration) string {
+	h, m := d/time.Hour, d%time.Hour/time.Minute
+	s, ns := d%time.Minute/time.Second, d%time.Second
+	return fmt.Sprintf("PT%dH%dM%d.%09dS", h, m, s, ns)
+}
+
+func FuzzParseISO8601Duration(f *testing.F) {
+	for _, seed := range []string{
+		"PT4M13S",
+		"P0D",
+		"P1DT2H",
+		"PT1.5S",
+		"P1Y2M3W4DT5H6M7,5S",
+		"PT2562047H47M16.854775807S",
+		"PT",
+		"PT1H1H",
+		"PT-1S",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		d, err := ParseISO8601Duration(s)
+		if err != nil {
+			require.ErrorIs(t, err, ErrInvalidDuration)
+			return
+		}
+		require.GreaterOrEqual(t, d, time.Duration(0))
+
+		again, err := ParseISO8601Duration(formatISO8601Duration(d))
+		require.NoError(t, err)
+		require.Equal(t, d, again)
+	})
+}
@@ -0,0 +1,96 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"time"
+
+	"github.com/tifye/shigure/raster"
+)
+
+const (
+	imageCardWidth  = 320
+	imageCardHeight = 180
+	// imageScale is the pixel density cards are rasterized at
+	// so they stay sharp on high density displays.
+	imageScale = 2
+)
+
+var (
+	imageBackground = raster.MustParseHexColor("#0f0f0f")
+	imageTitleBar   = raster.MustParseHexColor("#00000099")
+	imageForeground = raster.MustParseHexColor("#ffffff")
+	imageProgress   = raster.MustParseHexColor("#ff0033")
+)
+
+// StreamImage renders the current activity as a raster card in
+// the layout of the default SVG theme. Renders are cached per
+// format like those of StreamSVG.
+func (c *ActivityClient) StreamImage(ctx context.Context, out io.Writer, format raster.Format) error {
+	now := time.Now()
+	c.mu.RLock()
+	activity, version := c.activityAt(now), c.version
+	c.mu.RUnlock()
+
+	key := "image:" + string(format)
+	c.cardMu.Lock()
+	cached, ok := c.cardCache[key]
+	c.cardMu.Unlock()
+	if ok && cached.version == version && !progressStale(activity, cached.renderedAt, now) {
+		_, err := out.Write(cached.data)
+		return err
+	}
+
+	c.logger.Debug("re-building youtube activity image", "format", format, "version", version)
+
+	var thumbnail image.Image
+	if t, ok := c.thumbnail(ctx, activity); ok {
+		img, err := t.Image()
+		if err != nil {
+			// Still worth showing the title without the thumbnail
+			c.logger.Warn("decode activity thumb", "err", err, "url", activity.ThumbnailUrl)
+		}
+		thumbnail = img
+	}
+
+	var buf bytes.Buffer
+	if err := raster.Encode(&buf, renderActivityImage(activity, thumbnail), format); err != nil {
+		return err
+	}
+
+	c.cacheCard(key, cachedCard{version: version, renderedAt: now, data: buf.Bytes()})
+	_, err := out.Write(buf.Bytes())
+	return err
+}
+
+func renderActivityImage(a Activity, thumbnail image.Image) *image.RGBA {
+	w, h := imageCardWidth*imageScale, imageCardHeight*imageScale
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	raster.FillRect(img, img.Bounds(), imageBackground)
+	if thumbnail != nil {
+		raster.DrawCover(img, img.Bounds(), thumbnail)
+	}
+
+	barHeight := 24 * imageScale
+	raster.FillRect(img, image.Rect(0, 0, w, barHeight), imageTitleBar)
+	face := raster.Face(13*imageScale, false)
+	defer face.Close()
+	padding := 8 * imageScale
+	title := raster.Truncate(face, fmt.Sprintf("%s - %s", a.Title, a.Author), w-2*padding)
+	raster.DrawText(img, face, padding, 17*imageScale, imageForeground, title)
+
+	if a.Id != placeholderVideoID && a.Live {
+		live := raster.Face(10*imageScale, false)
+		defer live.Close()
+		pill := image.Rect(padding, h-padding-16*imageScale, padding+raster.TextWidth(live, "LIVE")+8*imageScale, h-padding)
+		raster.FillRect(img, pill, imageProgress)
+		raster.DrawText(img, live, pill.Min.X+4*imageScale, pill.Max.Y-4*imageScale, imageForeground, "LIVE")
+	} else if a.Id != placeholderVideoID && a.Duration > 0 {
+		progress := int(float64(w) * min(max(a.Progress, 0), 1))
+		raster.FillRect(img, image.Rect(0, h-4*imageScale, progress, h), imageProgress)
+	}
+	return img
+}
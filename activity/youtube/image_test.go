@@ -0,0 +1,61 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tifye/shigure/raster"
+)
+
+func TestRenderActivityImage(t *testing.T) {
+	thumbnail := image.NewRGBA(image.Rect(0, 0, 16, 9))
+	raster.FillRect(thumbnail, thumbnail.Bounds(), raster.MustParseHexColor("#00ff00"))
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, thumbnail))
+	decoded, err := Thumbnail{Data: encoded.Bytes(), ContentType: "image/png"}.Image()
+	require.NoError(t, err)
+
+	img := renderActivityImage(Activity{
+		Id:       "dQw4w9WgXcQ",
+		Title:    "Never Gonna Give You Up",
+		Author:   "Rick Astley",
+		Duration: time.Minute,
+		Progress: 0.5,
+	}, decoded)
+	require.Equal(t, image.Rect(0, 0, imageCardWidth*imageScale, imageCardHeight*imageScale), img.Bounds())
+
+	h := img.Bounds().Dy()
+	// Thumbnail in the middle, progress bar half way along the bottom
+	r, g, b, _ := img.At(10, h/2).RGBA()
+	assert.Equal(t, [3]uint32{0, 0xffff, 0}, [3]uint32{r, g, b})
+	r, g, b, _ = img.At(10, h-1).RGBA()
+	assert.Equal(t, [3]uint32{0xffff, 0, 0x3333}, [3]uint32{r, g, b})
+	r, g, b, _ = img.At(img.Bounds().Dx()-10, h-1).RGBA()
+	assert.Equal(t, [3]uint32{0, 0xffff, 0}, [3]uint32{r, g, b})
+}
+
+func TestStreamImage(t *testing.T) {
+	client := &ActivityClient{
+		logger:          log.New(io.Discard),
+		cardCache:       map[string]cachedCard{},
+		currentActivity: Activity{Id: placeholderVideoID, Title: "(─‿‿─)"},
+	}
+
+	for _, format := range []raster.Format{raster.PNG, raster.WebP} {
+		var buf bytes.Buffer
+		require.NoError(t, client.StreamImage(context.Background(), &buf, format))
+		_, name, err := image.Decode(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, string(format), name)
+	}
+	assert.Contains(t, client.cardCache, "image:png")
+	assert.Contains(t, client.cardCache, "image:webp")
+}
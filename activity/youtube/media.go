@@ -0,0 +1,60 @@
+package youtube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	ProviderYoutube = "youtube"
+	ProviderTwitch  = "twitch"
+	ProviderOEmbed  = "oembed"
+	ProviderMPRIS   = "mpris"
+
+	// DefaultMediaDuration is how long media of unknown
+	// duration is shown before being cleared.
+	DefaultMediaDuration = 30 * time.Minute
+
+	maxMediaIDLen = 31
+
+	// providerRequestTimeout bounds requests to the APIs of
+	// providers, which are made while media is being set.
+	providerRequestTimeout = 10 * time.Second
+)
+
+// providerClient is the client shared by the providers.
+var providerClient = &http.Client{Timeout: providerRequestTimeout}
+
+var (
+	ErrUnknownProvider = errors.New("unknown media provider")
+	ErrMediaNotFound   = errors.New("media not found")
+	// ErrSkipMedia is returned by providers for media that
+	// should not become the current activity.
+	ErrSkipMedia = errors.New("skip media")
+	// ErrProviderUnavailable is returned when the API of a
+	// provider could not be reached or failed.
+	ErrProviderUnavailable = errors.New("media provider unavailable")
+)
+
+// MediaProvider resolves the id of a piece of media, such as
+// a video id or a URL, into the activity of watching or
+// listening to it.
+type MediaProvider interface {
+	// Name identifies the provider, e.g. youtube.
+	Name() string
+	Resolve(ctx context.Context, id string) (Activity, error)
+}
+
+// mediaID returns id if it is short enough to be stored,
+// otherwise a hash of it.
+func mediaID(id string) string {
+	if len(id) <= maxMediaIDLen {
+		return id
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])[:maxMediaIDLen]
+}
@@ -0,0 +1,283 @@
+package youtube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestYoutubeProviderResolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/videos", r.URL.Path)
+		assert.Equal(t, "key", r.Header.Get("X-Goog-Api-Key"))
+		assert.Empty(t, r.URL.Query().Get("key"))
+		switch r.URL.Query().Get("id") {
+		case "abc":
+			w.Write([]byte(`{"items":[{"id":"abc","snippet":{"title":"Title","channelTitle":"Channel","thumbnails":{"high":{"url":"https://i.ytimg.com/abc.jpg"}}},"contentDetails":{"duration":"PT3M20S"}}]}`))
+		case "long":
+			w.Write([]byte(`{"items":[{"id":"long","contentDetails":{"duration":"P1DT2H"}}]}`))
+		case "live":
+			w.Write([]byte(`{"items":[{"id":"live","snippet":{"liveBroadcastContent":"live"},"contentDetails":{"duration":"P0D"}}]}`))
+		case "premiere":
+			w.Write([]byte(`{"items":[{"id":"premiere","snippet":{"liveBroadcastContent":"upcoming"},"contentDetails":{"duration":"PT3M"}}]}`))
+		case "invalid":
+			w.Write([]byte(`{"items":[{"id":"invalid","contentDetails":{"duration":"3 minutes"}}]}`))
+		default:
+			w.Write([]byte(`{"items":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	p, err := NewYoutubeProvider("key", YoutubeProviderOptions{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	p.baseURL = srv.URL
+
+	a, err := p.Resolve(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.Equal(t, Activity{
+		Provider:     ProviderYoutube,
+		Id:           "abc",
+		Url:          "https://www.youtube.com/watch?v=abc",
+		Title:        "Title",
+		Author:       "Channel",
+		ThumbnailUrl: "https://i.ytimg.com/abc.jpg",
+		Duration:     3*time.Minute + 20*time.Second,
+	}, a)
+
+	a, err = p.Resolve(context.Background(), "long")
+	require.NoError(t, err)
+	assert.Equal(t, 26*time.Hour, a.Duration)
+	assert.False(t, a.Live)
+
+	for _, id := range []string{"live", "premiere"} {
+		a, err = p.Resolve(context.Background(), id)
+		require.NoError(t, err)
+		assert.True(t, a.Live, id)
+		assert.Zero(t, a.Duration, id)
+	}
+
+	_, err = p.Resolve(context.Background(), "invalid")
+	assert.ErrorIs(t, err, ErrProviderUnavailable)
+
+	_, err = p.Resolve(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrMediaNotFound)
+}
+
+func TestYoutubeProviderCachesVideos(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"items":[{"id":"abc","snippet":{"title":"Title"},"contentDetails":{"duration":"PT1M"}}]}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	p, err := NewYoutubeProvider("key", YoutubeProviderOptions{CacheDir: dir, CacheSize: 1})
+	require.NoError(t, err)
+	p.baseURL = srv.URL
+
+	for range 3 {
+		a, err := p.Resolve(context.Background(), "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "Title", a.Title)
+	}
+	assert.Equal(t, 1, requests)
+
+	status := p.Status()
+	assert.Equal(t, 1, status.Quota.Used)
+	assert.Equal(t, VideoCacheStats{Entries: 1, Hits: 2, Misses: 1}, status.Cache)
+
+	// Restarts keep the cache and the quota used
+	p, err = NewYoutubeProvider("key", YoutubeProviderOptions{CacheDir: dir})
+	require.NoError(t, err)
+	p.baseURL = srv.URL
+	_, err = p.Resolve(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, 1, p.Status().Quota.Used)
+}
+
+func TestYoutubeProviderQuotaFallback(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"items":[{"id":"abc","contentDetails":{"duration":"PT1M"}}]}`))
+	}))
+	defer srv.Close()
+	oembed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "https://www.youtube.com/watch?v=def", r.URL.Query().Get("url"))
+		w.Write([]byte(`{"title":"Fallback","author_name":"Channel"}`))
+	}))
+	defer oembed.Close()
+
+	p, err := NewYoutubeProvider("key", YoutubeProviderOptions{
+		CacheDir:   t.TempDir(),
+		QuotaLimit: 1,
+		Fallback:   NewOEmbedProvider(oembed.URL),
+	})
+	require.NoError(t, err)
+	p.baseURL = srv.URL
+
+	_, err = p.Resolve(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.True(t, p.Status().Degraded)
+
+	a, err := p.Resolve(context.Background(), "def")
+	require.NoError(t, err)
+	assert.Equal(t, Activity{
+		Provider: ProviderYoutube,
+		Id:       "def",
+		Url:      "https://www.youtube.com/watch?v=def",
+		Title:    "Fallback",
+		Author:   "Channel",
+	}, a)
+	assert.Equal(t, 1, requests, "expected no requests beyond the limit")
+	assert.True(t, p.Status().Degraded)
+
+	// Without a fallback the exhausted quota is the error
+	p.fallback = nil
+	_, err = p.Resolve(context.Background(), "def")
+	assert.ErrorIs(t, err, ErrQuotaExhausted)
+}
+
+func TestYoutubeProviderQuotaExceededResponse(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":{"code":403,"errors":[{"reason":"quotaExceeded"}]}}`))
+	}))
+	defer srv.Close()
+
+	p, err := NewYoutubeProvider("key", YoutubeProviderOptions{CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	p.baseURL = srv.URL
+
+	_, err = p.Resolve(context.Background(), "abc")
+	assert.ErrorIs(t, err, ErrQuotaExhausted)
+	_, err = p.Resolve(context.Background(), "def")
+	assert.ErrorIs(t, err, ErrQuotaExhausted)
+	assert.Equal(t, 1, requests)
+
+	status := p.Status()
+	assert.True(t, status.Quota.Exhausted)
+	assert.True(t, status.Degraded)
+	assert.Zero(t, status.Quota.Remaining())
+}
+
+func TestQuotaTrackerResetsDaily(t *testing.T) {
+	q, err := newQuotaTracker(t.TempDir()+"/quota.json", 2)
+	require.NoError(t, err)
+
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, quotaLocation())
+	require.NoError(t, q.reserve(day, 2))
+	assert.ErrorIs(t, q.reserve(day, 1), ErrQuotaExhausted)
+	assert.Equal(t, time.Date(2026, 3, 3, 0, 0, 0, 0, quotaLocation()), q.usage(day).ResetsAt)
+
+	next := day.Add(12 * time.Hour)
+	require.NoError(t, q.reserve(next, 1))
+	assert.Equal(t, QuotaUsage{
+		Day:      "2026-03-03",
+		Used:     1,
+		Limit:    2,
+		ResetsAt: time.Date(2026, 3, 4, 0, 0, 0, 0, quotaLocation()),
+	}, q.usage(next))
+}
+
+func TestTwitchProviderResolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "client", r.Header.Get("Client-Id"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		if r.URL.Query().Get("id") != "123" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data":[{"id":"123","title":"Stream","user_name":"Streamer","url":"https://www.twitch.tv/videos/123","thumbnail_url":"https://static-cdn.jtvnw.net/123-%{width}x%{height}.jpg","duration":"1h2m3s"}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewTwitchProvider("client", "token")
+	p.baseURL = srv.URL
+
+	a, err := p.Resolve(context.Background(), "123")
+	require.NoError(t, err)
+	assert.Equal(t, Activity{
+		Provider:     ProviderTwitch,
+		Id:           "123",
+		Url:          "https://www.twitch.tv/videos/123",
+		Title:        "Stream",
+		Author:       "Streamer",
+		ThumbnailUrl: "https://static-cdn.jtvnw.net/123-640x360.jpg",
+		Duration:     time.Hour + 2*time.Minute + 3*time.Second,
+	}, a)
+
+	_, err = p.Resolve(context.Background(), "456")
+	assert.ErrorIs(t, err, ErrMediaNotFound)
+}
+
+func TestOEmbedProviderResolve(t *testing.T) {
+	const mediaURL = "https://vimeo.com/76979871"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("url") != mediaURL {
+			w.Write([]byte(`{"error":"no matching providers found"}`))
+			return
+		}
+		w.Write([]byte(`{"title":"Video","provider_name":"Vimeo","thumbnail_url":"https://i.vimeocdn.com/1.jpg"}`))
+	}))
+	defer srv.Close()
+
+	p := NewOEmbedProvider(srv.URL + "/embed")
+
+	a, err := p.Resolve(context.Background(), mediaURL)
+	require.NoError(t, err)
+	assert.Equal(t, Activity{
+		Provider:     ProviderOEmbed,
+		Id:           mediaID(mediaURL),
+		Url:          mediaURL,
+		Title:        "Video",
+		Author:       "Vimeo",
+		ThumbnailUrl: "https://i.vimeocdn.com/1.jpg",
+	}, a)
+
+	_, err = p.Resolve(context.Background(), "https://example.com/unknown")
+	assert.ErrorIs(t, err, ErrMediaNotFound)
+
+	_, err = p.Resolve(context.Background(), "file:///music/song.mp3")
+	assert.ErrorIs(t, err, ErrMediaNotFound)
+}
+
+func TestMPRISMetadataActivity(t *testing.T) {
+	a, err := MPRISMetadata{
+		TrackID: "/org/mpris/MediaPlayer2/Track/1",
+		Title:   "Song",
+		Artist:  []string{"A", "B"},
+		ArtUrl:  "file:///home/user/.cache/art.png",
+		Url:     "https://example.com/song",
+		Length:  int64(3 * time.Minute / time.Microsecond),
+	}.Activity()
+	require.NoError(t, err)
+	assert.Equal(t, Activity{
+		Provider: ProviderMPRIS,
+		Id:       mediaID("/org/mpris/MediaPlayer2/Track/1"),
+		Title:    "Song",
+		Author:   "A, B",
+		Url:      "https://example.com/song",
+		Duration: 3 * time.Minute,
+	}, a)
+
+	_, err = MPRISMetadata{Title: " "}.Activity()
+	assert.Error(t, err)
+}
+
+func TestMediaID(t *testing.T) {
+	assert.Equal(t, "dQw4w9WgXcQ", mediaID("dQw4w9WgXcQ"))
+
+	long := mediaID("https://example.com/a/very/long/url/to/some/media")
+	assert.Len(t, long, maxMediaIDLen)
+	assert.Equal(t, long, mediaID("https://example.com/a/very/long/url/to/some/media"))
+}
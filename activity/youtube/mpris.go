@@ -0,0 +1,62 @@
+package youtube
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MPRISMetadata is the metadata of the playing track as exposed
+// by media players over MPRIS, see
+// https://www.freedesktop.org/wiki/Specifications/mpris-spec/metadata.
+// It is pushed by a local agent rather than resolved.
+type MPRISMetadata struct {
+	TrackID string   `json:"mpris:trackid"`
+	Title   string   `json:"xesam:title"`
+	Artist  []string `json:"xesam:artist"`
+	Album   string   `json:"xesam:album"`
+	ArtUrl  string   `json:"mpris:artUrl"`
+	Url     string   `json:"xesam:url"`
+	// Length of the track in microseconds.
+	Length int64 `json:"mpris:length"`
+}
+
+// Activity converts the metadata into the activity of
+// listening to the track.
+func (m MPRISMetadata) Activity() (Activity, error) {
+	if strings.TrimSpace(m.Title) == "" {
+		return Activity{}, fmt.Errorf("missing xesam:title")
+	}
+	if m.Length < 0 {
+		return Activity{}, fmt.Errorf("negative mpris:length")
+	}
+
+	id := m.TrackID
+	if id == "" {
+		id = m.Url + "\x00" + m.Title
+	}
+	author := strings.Join(m.Artist, ", ")
+	if author == "" {
+		author = m.Album
+	}
+
+	return Activity{
+		Provider: ProviderMPRIS,
+		Id:       mediaID(id),
+		Title:    m.Title,
+		Author:   author,
+		// Local files and art can't be linked to or embedded
+		Url:          httpURL(m.Url),
+		ThumbnailUrl: httpURL(m.ArtUrl),
+		Duration:     time.Duration(m.Length) * time.Microsecond,
+	}, nil
+}
+
+func httpURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return s
+}
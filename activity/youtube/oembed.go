@@ -0,0 +1,126 @@
+package youtube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/tifye/shigure/assert"
+)
+
+// OEmbedProvider resolves URLs of any site with an oEmbed
+// endpoint, see https://oembed.com. oEmbed has no notion of
+// duration so DefaultMediaDuration applies.
+type OEmbedProvider struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewOEmbedProvider creates a provider resolving URLs using the
+// oEmbed endpoint, e.g. https://noembed.com/embed.
+func NewOEmbedProvider(endpoint string) *OEmbedProvider {
+	assert.AssertNotEmpty(endpoint)
+	return &OEmbedProvider{
+		endpoint: endpoint,
+		client:   providerClient,
+	}
+}
+
+func (p *OEmbedProvider) Name() string {
+	return ProviderOEmbed
+}
+
+type oEmbedResponse struct {
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	ProviderName string `json:"provider_name"`
+	ThumbnailUrl string `json:"thumbnail_url"`
+	// Some endpoints report errors with a 200 status
+	Error string `json:"error"`
+}
+
+func (p *OEmbedProvider) Resolve(ctx context.Context, mediaURL string) (Activity, error) {
+	u, err := url.Parse(mediaURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return Activity{}, fmt.Errorf("%w: invalid url %q", ErrMediaNotFound, mediaURL)
+	}
+
+	endpoint, err := url.Parse(p.endpoint)
+	if err != nil {
+		return Activity{}, err
+	}
+	query := endpoint.Query()
+	query.Set("url", mediaURL)
+	query.Set("format", "json")
+	endpoint.RawQuery = query.Encode()
+
+	var resp oEmbedResponse
+	if err := getJSON(ctx, p.client, endpoint.String(), nil, &resp); err != nil {
+		return Activity{}, err
+	}
+	if resp.Error != "" {
+		return Activity{}, fmt.Errorf("%w: %s", ErrMediaNotFound, resp.Error)
+	}
+
+	author := resp.AuthorName
+	if author == "" {
+		author = resp.ProviderName
+	}
+	return Activity{
+		Provider:     ProviderOEmbed,
+		Id:           mediaID(mediaURL),
+		Title:        resp.Title,
+		Author:       author,
+		Url:          mediaURL,
+		ThumbnailUrl: resp.ThumbnailUrl,
+	}, nil
+}
+
+// maxErrorBody is how much of the body of failed responses is
+// kept, enough for the JSON errors of APIs.
+const maxErrorBody = 64 << 10
+
+// responseError is a non 2xx response of a provider's API.
+type responseError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("response failed with code %d", e.StatusCode)
+}
+
+func (e *responseError) Unwrap() error {
+	return ErrProviderUnavailable
+}
+
+// getJSON decodes the JSON response to a GET request of u
+// into v, responses with a non 2xx status are a *responseError.
+func getJSON(ctx context.Context, client *http.Client, u string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrProviderUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrMediaNotFound
+	}
+	if res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+		return &responseError{StatusCode: res.StatusCode, Body: body}
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
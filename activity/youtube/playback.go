@@ -0,0 +1,91 @@
+package youtube
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// MaxPlaybackRate is the fastest rate players are expected
+	// to report, YouTube allows up to 2x and most players 4x.
+	MaxPlaybackRate = 16
+
+	// maxPausedDuration is how long paused media is kept as the
+	// activity without the player reporting on it.
+	maxPausedDuration = 30 * time.Minute
+
+	// liveActivityTimeout is how long live media is kept as the
+	// activity after the player last reported on it, as there is
+	// no end to expect.
+	liveActivityTimeout = 10 * time.Minute
+)
+
+var (
+	ErrInvalidPlayback = errors.New("invalid playback")
+	// ErrPlaybackMismatch is returned for playback reported on
+	// media other than the current activity.
+	ErrPlaybackMismatch = errors.New("playback of media other than the current activity")
+)
+
+// PlaybackState is the state of the player as reported at
+// some point in time.
+type PlaybackState struct {
+	Position time.Duration
+	Rate     float64
+	Paused   bool
+}
+
+func (p PlaybackState) Validate() error {
+	if p.Position < 0 {
+		return fmt.Errorf("%w: negative position", ErrInvalidPlayback)
+	}
+	if p.Rate <= 0 || p.Rate > MaxPlaybackRate {
+		return fmt.Errorf("%w: rate must be in (0, %d]", ErrInvalidPlayback, MaxPlaybackRate)
+	}
+	return nil
+}
+
+// playback is the last reported PlaybackState of media with
+// the duration, or of live media.
+type playback struct {
+	PlaybackState
+	reportedAt time.Time
+	duration   time.Duration
+	live       bool
+}
+
+// position estimates the position of playback at now, never
+// beyond the duration of media that is not live.
+func (p playback) position(now time.Time) time.Duration {
+	pos := p.Position
+	if !p.Paused && now.After(p.reportedAt) {
+		pos += time.Duration(float64(now.Sub(p.reportedAt)) * p.Rate)
+	}
+	if p.live {
+		return pos
+	}
+	return min(pos, p.duration)
+}
+
+// progress is the position at now as a fraction of the duration.
+func (p playback) progress(now time.Time) float64 {
+	if p.duration <= 0 {
+		return 0
+	}
+	return float64(p.position(now)) / float64(p.duration)
+}
+
+// expectedEnd is when playback reaches the end of the media
+// at the reported rate, or when paused or live media is given
+// up on.
+func (p playback) expectedEnd() time.Time {
+	if p.live {
+		return p.reportedAt.Add(liveActivityTimeout)
+	}
+	if p.Paused {
+		return p.reportedAt.Add(maxPausedDuration)
+	}
+	remaining := max(p.duration-p.Position, 0)
+	return p.reportedAt.Add(time.Duration(float64(remaining) / p.Rate))
+}
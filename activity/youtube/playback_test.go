@@ -0,0 +1,128 @@
+package youtube
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tifye/shigure/mux"
+)
+
+func TestPlaybackEstimate(t *testing.T) {
+	reportedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		state    PlaybackState
+		elapsed  time.Duration
+		position time.Duration
+		end      time.Duration
+	}{
+		{
+			name:     "playing",
+			state:    PlaybackState{Position: time.Minute, Rate: 1},
+			elapsed:  time.Minute,
+			position: 2 * time.Minute,
+			end:      9 * time.Minute,
+		},
+		{
+			name:     "double speed",
+			state:    PlaybackState{Position: time.Minute, Rate: 2},
+			elapsed:  time.Minute,
+			position: 3 * time.Minute,
+			end:      4*time.Minute + 30*time.Second,
+		},
+		{
+			name:     "paused",
+			state:    PlaybackState{Position: time.Minute, Rate: 1, Paused: true},
+			elapsed:  time.Hour,
+			position: time.Minute,
+			end:      maxPausedDuration,
+		},
+		{
+			name:     "past the end",
+			state:    PlaybackState{Position: 9 * time.Minute, Rate: 1},
+			elapsed:  time.Hour,
+			position: 10 * time.Minute,
+			end:      time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := playback{PlaybackState: tt.state, reportedAt: reportedAt, duration: 10 * time.Minute}
+			now := reportedAt.Add(tt.elapsed)
+			assert.Equal(t, tt.position, p.position(now))
+			assert.InDelta(t, float64(tt.position)/float64(10*time.Minute), p.progress(now), 1e-9)
+			assert.Equal(t, reportedAt.Add(tt.end), p.expectedEnd())
+		})
+	}
+}
+
+func TestPlaybackStateValidate(t *testing.T) {
+	assert.NoError(t, PlaybackState{Rate: 1}.Validate())
+	assert.ErrorIs(t, PlaybackState{Rate: 0}.Validate(), ErrInvalidPlayback)
+	assert.ErrorIs(t, PlaybackState{Rate: MaxPlaybackRate + 1}.Validate(), ErrInvalidPlayback)
+	assert.ErrorIs(t, PlaybackState{Position: -time.Second, Rate: 1}.Validate(), ErrInvalidPlayback)
+}
+
+func TestSetPlayback(t *testing.T) {
+	m := mux.NewMux(log.New(io.Discard))
+	client := &ActivityClient{
+		logger:         log.New(io.Discard),
+		store:          newTestStore(t),
+		mux:            m,
+		muxMessageType: "youtube",
+	}
+	client.lastUpdate.Store(time.Now())
+	client.setActivity(context.Background(), Activity{Id: "dQw4w9WgXcQ", Duration: 3 * time.Minute})
+
+	err := client.SetPlayback("other", PlaybackState{Rate: 1})
+	assert.ErrorIs(t, err, ErrPlaybackMismatch)
+
+	playingVersion, _ := client.Version()
+	require.NoError(t, client.SetPlayback("dQw4w9WgXcQ", PlaybackState{Position: time.Minute, Rate: 1, Paused: true}))
+	pausedVersion, _ := client.Version()
+	assert.NotEqual(t, playingVersion, pausedVersion)
+	a := client.Activity()
+	assert.Equal(t, time.Minute, a.Position)
+	assert.True(t, a.Paused)
+	assert.InDelta(t, 1.0/3, a.Progress, 1e-9)
+
+	// Positions past the end are clamped
+	require.NoError(t, client.SetPlayback("", PlaybackState{Position: time.Hour, Rate: 1, Paused: true}))
+	assert.Equal(t, 3*time.Minute, client.Activity().Position)
+}
+
+func TestLivePlayback(t *testing.T) {
+	reportedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := playback{PlaybackState: PlaybackState{Position: time.Hour, Rate: 1}, reportedAt: reportedAt, live: true}
+	now := reportedAt.Add(time.Hour)
+	assert.Equal(t, 2*time.Hour, p.position(now))
+	assert.Zero(t, p.progress(now))
+	assert.Equal(t, reportedAt.Add(liveActivityTimeout), p.expectedEnd())
+
+	m := mux.NewMux(log.New(io.Discard))
+	client := &ActivityClient{
+		logger:         log.New(io.Discard),
+		store:          newTestStore(t),
+		mux:            m,
+		muxMessageType: "youtube",
+	}
+	client.lastUpdate.Store(time.Now())
+	client.SetActivity(context.Background(), Activity{Id: "jfKfPfyJRdk", Live: true})
+	assert.Zero(t, client.Activity().Duration, "live media has no default duration")
+
+	// Positions are not clamped to the lack of duration
+	require.NoError(t, client.SetPlayback("jfKfPfyJRdk", PlaybackState{Position: time.Hour, Rate: 1, Paused: true}))
+	assert.Equal(t, time.Hour, client.Activity().Position)
+
+	end := client.expectedEnd()
+	time.Sleep(time.Millisecond)
+	client.keepLive()
+	assert.True(t, client.expectedEnd().After(end))
+	assert.Equal(t, time.Hour, client.Activity().Position)
+}
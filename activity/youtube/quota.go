@@ -0,0 +1,159 @@
+package youtube
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tifye/shigure/assert"
+)
+
+const (
+	// DefaultYoutubeQuotaLimit is the daily quota granted to
+	// projects of the YouTube Data API.
+	DefaultYoutubeQuotaLimit = 10000
+
+	// videosListQuotaCost is the units a videos.list request
+	// costs, whatever the parts requested.
+	videosListQuotaCost = 1
+)
+
+var ErrQuotaExhausted = errors.New("youtube quota exhausted")
+
+// quotaLocation is the time zone the quota resets in at midnight.
+var quotaLocation = sync.OnceValue(func() *time.Location {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		// Without tzdata, off by an hour during daylight saving
+		return time.FixedZone("PST", -8*60*60)
+	}
+	return loc
+})
+
+// QuotaUsage is the quota used on a day, days starting at
+// midnight Pacific Time like those of the YouTube Data API.
+type QuotaUsage struct {
+	Day      string    `json:"day"`
+	Used     int       `json:"used"`
+	Limit    int       `json:"limit"`
+	ResetsAt time.Time `json:"resetsAt"`
+	// Exhausted is set when the API refused requests for lack
+	// of quota before Limit was reached, e.g. because the key is
+	// shared with other applications.
+	Exhausted bool `json:"exhausted"`
+}
+
+func (u QuotaUsage) Remaining() int {
+	if u.Exhausted {
+		return 0
+	}
+	return max(u.Limit-u.Used, 0)
+}
+
+type quotaState struct {
+	Day       string `json:"day"`
+	Used      int    `json:"used"`
+	Exhausted bool   `json:"exhausted"`
+}
+
+// quotaTracker counts the quota units spent each day and refuses
+// to spend more than the limit. The count is persisted so that
+// restarts do not reset it.
+type quotaTracker struct {
+	path  string
+	limit int
+
+	mu    sync.Mutex
+	state quotaState
+}
+
+func newQuotaTracker(path string, limit int) (*quotaTracker, error) {
+	assert.AssertNotEmpty(path)
+	assert.Assert(limit > 0, "expected positive quota limit")
+
+	q := &quotaTracker{
+		path:  path,
+		limit: limit,
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(contents) > 0 {
+		if err := json.Unmarshal(contents, &q.state); err != nil {
+			return nil, fmt.Errorf("parse quota: %s", err)
+		}
+	}
+	return q, nil
+}
+
+func quotaDay(now time.Time) string {
+	return now.In(quotaLocation()).Format(time.DateOnly)
+}
+
+// rollover starts a new count on a new day, the caller must
+// hold mu.
+func (q *quotaTracker) rollover(now time.Time) {
+	if day := quotaDay(now); q.state.Day != day {
+		q.state = quotaState{Day: day}
+	}
+}
+
+// reserve spends units of the quota of the day, the request
+// costing them should only be made when it succeeds.
+func (q *quotaTracker) reserve(now time.Time, units int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollover(now)
+	if q.state.Exhausted || q.state.Used+units > q.limit {
+		return fmt.Errorf("%w: %d of %d units used", ErrQuotaExhausted, q.state.Used, q.limit)
+	}
+	q.state.Used += units
+	return q.save()
+}
+
+// exhaust marks the quota of the day as used up, for when the
+// API says so.
+func (q *quotaTracker) exhaust(now time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollover(now)
+	q.state.Exhausted = true
+	return q.save()
+}
+
+func (q *quotaTracker) usage(now time.Time) QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollover(now)
+	local := now.In(quotaLocation())
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	return QuotaUsage{
+		Day:       q.state.Day,
+		Used:      q.state.Used,
+		Limit:     q.limit,
+		ResetsAt:  midnight.AddDate(0, 0, 1),
+		Exhausted: q.state.Exhausted,
+	}
+}
+
+// save persists the state, the caller must hold mu.
+func (q *quotaTracker) save() error {
+	contents, err := json.Marshal(q.state)
+	if err != nil {
+		return err
+	}
+
+	tempPath := q.path + "_temp"
+	if err := os.WriteFile(tempPath, contents, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, q.path)
+}
@@ -0,0 +1,101 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	statsTopChannels  = 10
+	statsRecentVideos = 10
+)
+
+type Stats struct {
+	From           time.Time     `json:"from,omitzero"`
+	To             time.Time     `json:"to,omitzero"`
+	TotalWatchTime string        `json:"totalWatchTime"`
+	VideosWatched  uint          `json:"videosWatched"`
+	ChannelStats   []ChannelStat `json:"channels"`
+	RecentVideos   []VideoStat   `json:"recentVideos"`
+}
+
+type ChannelStat struct {
+	Channel    string  `json:"channel"`
+	Videos     uint    `json:"videos"`
+	Percentage float64 `json:"percentage"`
+	WatchTime  string  `json:"watchTime"`
+}
+
+type VideoStat struct {
+	Provider  string    `json:"provider"`
+	Id        string    `json:"id"`
+	Title     string    `json:"title"`
+	Channel   string    `json:"channel"`
+	Url       string    `json:"url"`
+	Duration  string    `json:"duration"`
+	WatchTime string    `json:"watchTime"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// Stats returns the total watch time, the most watched channels
+// and the most recently watched videos within filter.
+func (c *ActivityClient) Stats(ctx context.Context, filter StatsFilter) (Stats, error) {
+	now := time.Now()
+
+	total, err := c.store.TotalWatchTime(ctx, filter, now)
+	if err != nil {
+		return Stats{}, fmt.Errorf("get total watch time: %s", err)
+	}
+
+	channels, err := c.store.ChannelReports(ctx, filter, now, statsTopChannels)
+	if err != nil {
+		return Stats{}, fmt.Errorf("get stored channel reports: %s", err)
+	}
+
+	watches, err := c.store.RecentWatches(ctx, filter, now, statsRecentVideos)
+	if err != nil {
+		return Stats{}, fmt.Errorf("get stored recent watches: %s", err)
+	}
+
+	stats := Stats{
+		From:           filter.From,
+		To:             filter.To,
+		TotalWatchTime: secondsString(total.Seconds),
+		VideosWatched:  total.Videos,
+		ChannelStats:   make([]ChannelStat, len(channels)),
+		RecentVideos:   make([]VideoStat, len(watches)),
+	}
+	for i, report := range channels {
+		stats.ChannelStats[i] = ChannelStat{
+			Channel:    report.Channel,
+			Videos:     report.Videos,
+			Percentage: math.Floor(report.OverallPercent*100) / 100,
+			WatchTime:  secondsString(report.Seconds),
+		}
+	}
+	for i, w := range watches {
+		// Videos watched before the url was stored are all youtube videos
+		url := w.Url
+		if url == "" && w.Provider == ProviderYoutube {
+			url = fmt.Sprintf("https://www.youtube.com/watch?v=%s", w.VideoID)
+		}
+		stats.RecentVideos[i] = VideoStat{
+			Provider:  w.Provider,
+			Id:        w.VideoID,
+			Title:     w.Title,
+			Channel:   w.Channel,
+			Url:       url,
+			Duration:  (time.Duration(w.DurationSeconds) * time.Second).String(),
+			WatchTime: secondsString(w.Seconds),
+			StartedAt: w.StartedAt,
+		}
+	}
+
+	return stats, nil
+}
+
+func secondsString(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Truncate(time.Second).String()
+}
@@ -0,0 +1,217 @@
+package youtube
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/tifye/shigure/assert"
+	"github.com/tifye/shigure/storage"
+)
+
+// WatchedVideo is a video that was, or still is, being watched.
+type WatchedVideo struct {
+	Provider        string    `db:"provider"`
+	VideoID         string    `db:"video_id"`
+	Title           string    `db:"title"`
+	Channel         string    `db:"channel"`
+	Url             string    `db:"url"`
+	DurationSeconds uint      `db:"duration_seconds"`
+	StartedAt       time.Time `db:"started_at"`
+	// EndedAt is null while the video is being watched.
+	EndedAt sql.NullTime `db:"ended_at"`
+}
+
+type YoutubeActivityStore struct {
+	db storage.DuckDB
+}
+
+func NewYoutubeActivityStore(db storage.DuckDB) *YoutubeActivityStore {
+	assert.AssertNotNil(db)
+	return &YoutubeActivityStore{db: db}
+}
+
+func (s *YoutubeActivityStore) Insert(ctx context.Context, v WatchedVideo) error {
+	query := `
+	insert into youtube_activity (
+		provider,
+		video_id,
+		title,
+		channel,
+		url,
+		duration_seconds,
+		started_at,
+		ended_at
+	)
+	values (?,?,?,?,?,?,?,?)
+	`
+	_, err := s.db.ExecContext(
+		ctx, query,
+		v.Provider,
+		v.VideoID,
+		v.Title,
+		v.Channel,
+		v.Url,
+		v.DurationSeconds,
+		v.StartedAt.UTC(),
+		v.EndedAt,
+	)
+	return err
+}
+
+// End marks the video started at startedAt as no longer
+// being watched since endedAt.
+func (s *YoutubeActivityStore) End(ctx context.Context, videoID string, startedAt time.Time, endedAt time.Time) error {
+	query := `
+	update youtube_activity set ended_at = ?
+	where video_id = ? and started_at = ? and ended_at is null;
+	`
+	_, err := s.db.ExecContext(ctx, query, endedAt.UTC(), videoID, startedAt.UTC())
+	return err
+}
+
+// EndOpen marks the videos still being watched as no longer
+// being watched, for when the previous process stopped while
+// they were. When they were last watched is not known, they are
+// taken to have been watched until their end, or for
+// liveActivityTimeout for live streams which have no end. Videos
+// are never taken to end after now.
+func (s *YoutubeActivityStore) EndOpen(ctx context.Context, now time.Time) (int64, error) {
+	query := `
+	update youtube_activity set ended_at = least(
+		?::timestamp,
+		started_at + to_seconds(coalesce(nullif(duration_seconds, 0), ?))
+	)
+	where ended_at is null;
+	`
+	res, err := s.db.ExecContext(ctx, query, now.UTC(), int64(liveActivityTimeout.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// StatsFilter narrows down which watched videos are taken
+// into account by the stats queries. Zero values are ignored.
+type StatsFilter struct {
+	From time.Time
+	To   time.Time
+}
+
+func (f StatsFilter) where() (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	if !f.From.IsZero() {
+		conds = append(conds, "started_at >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "started_at < ?")
+		args = append(args, f.To.UTC())
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "where " + strings.Join(conds, " and "), args
+}
+
+// watchedQuery returns the CTE watched of the videos matching
+// where, with the seconds each was watched for. Videos are never
+// counted for longer than their duration, except live streams
+// which have none, videos still being watched are counted up
+// until now. now is the first arg of the
+// query, followed by the args of where.
+func watchedQuery(where string) string {
+	return `
+	with watched as (
+		select
+			provider,
+			video_id,
+			title,
+			channel,
+			coalesce(url, '') as url,
+			duration_seconds,
+			started_at,
+			greatest(0, least(
+				epoch(coalesce(ended_at, ?::timestamp) - started_at),
+				coalesce(nullif(duration_seconds, 0), 'infinity'::double)
+			)) as seconds
+		from youtube_activity
+		` + where + `
+	)
+	`
+}
+
+func (s *YoutubeActivityStore) query(ctx context.Context, dest any, filter StatsFilter, now time.Time, query string, args ...any) error {
+	where, whereArgs := filter.where()
+	args = append(append([]any{now.UTC()}, whereArgs...), args...)
+	return s.db.SelectContext(ctx, dest, watchedQuery(where)+query, args...)
+}
+
+type StoredTotalWatchTime struct {
+	Videos  uint    `db:"videos"`
+	Seconds float64 `db:"seconds"`
+}
+
+func (s *YoutubeActivityStore) TotalWatchTime(ctx context.Context, filter StatsFilter, now time.Time) (StoredTotalWatchTime, error) {
+	var totals []StoredTotalWatchTime
+	err := s.query(ctx, &totals, filter, now, `
+	select count(*) as videos, coalesce(sum(seconds), 0) as seconds
+	from watched;
+	`)
+	if err != nil || len(totals) == 0 {
+		return StoredTotalWatchTime{}, err
+	}
+	return totals[0], nil
+}
+
+type StoredChannelReport struct {
+	Channel string  `db:"channel"`
+	Videos  uint    `db:"videos"`
+	Seconds float64 `db:"seconds"`
+	// (Seconds / TotalSeconds) * 100
+	OverallPercent float64   `db:"percent"`
+	LastWatched    time.Time `db:"last_watched"`
+}
+
+func (s *YoutubeActivityStore) ChannelReports(ctx context.Context, filter StatsFilter, now time.Time, limit uint) ([]StoredChannelReport, error) {
+	var reports []StoredChannelReport
+	err := s.query(ctx, &reports, filter, now, `
+	select channel,
+		count(distinct video_id) as videos,
+		sum(seconds) as seconds,
+		coalesce(sum(seconds) / nullif(sum(sum(seconds)) over (), 0) * 100, 0) as "percent",
+		max(started_at) as last_watched
+	from watched
+	group by channel
+	order by seconds desc, videos desc
+	limit ?;
+	`, limit)
+	return reports, err
+}
+
+type StoredWatch struct {
+	Provider        string    `db:"provider"`
+	VideoID         string    `db:"video_id"`
+	Title           string    `db:"title"`
+	Channel         string    `db:"channel"`
+	Url             string    `db:"url"`
+	DurationSeconds uint      `db:"duration_seconds"`
+	StartedAt       time.Time `db:"started_at"`
+	Seconds         float64   `db:"seconds"`
+}
+
+// RecentWatches returns the most recently started videos first.
+func (s *YoutubeActivityStore) RecentWatches(ctx context.Context, filter StatsFilter, now time.Time, limit uint) ([]StoredWatch, error) {
+	var watches []StoredWatch
+	err := s.query(ctx, &watches, filter, now, `
+	select provider, video_id, title, channel, url, duration_seconds, started_at, seconds
+	from watched
+	order by started_at desc
+	limit ?;
+	`, limit)
+	return watches, err
+}
@@ -0,0 +1,88 @@
+package youtube
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tifye/shigure/storage"
+)
+
+func newTestStore(t *testing.T) *YoutubeActivityStore {
+	t.Helper()
+	db, err := storage.OpenDuckDB("")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return NewYoutubeActivityStore(db)
+}
+
+func TestStoreWatchTime(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	start := time.Date(2025, time.March, 10, 20, 0, 0, 0, time.UTC)
+	ended := func(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: true} }
+	videos := []WatchedVideo{
+		// Watched for 10 of 20 minutes
+		{VideoID: "a", Channel: "chocola", DurationSeconds: 20 * 60, StartedAt: start, EndedAt: ended(start.Add(10 * time.Minute))},
+		// Left open longer than its duration, counted as 5 minutes
+		{VideoID: "b", Channel: "chocola", DurationSeconds: 5 * 60, StartedAt: start.Add(10 * time.Minute), EndedAt: ended(start.Add(time.Hour))},
+		// Live streams have no duration, counted as 10 minutes
+		{VideoID: "live", Channel: "maple", StartedAt: start.Add(-10 * time.Minute), EndedAt: ended(start)},
+	}
+	for _, v := range videos {
+		require.NoError(t, store.Insert(ctx, v))
+	}
+	// Still being watched, ended once the stats are computed
+	require.NoError(t, store.Insert(ctx, WatchedVideo{VideoID: "c", Channel: "vanilla", DurationSeconds: 60 * 60, StartedAt: start.Add(time.Hour)}))
+	now := start.Add(time.Hour + 15*time.Minute)
+
+	total, err := store.TotalWatchTime(ctx, StatsFilter{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, uint(4), total.Videos)
+	assert.Equal(t, float64(40*60), total.Seconds)
+
+	channels, err := store.ChannelReports(ctx, StatsFilter{}, now, 10)
+	require.NoError(t, err)
+	if assert.Len(t, channels, 3) {
+		// Ties are broken by the amount of videos
+		assert.Equal(t, "chocola", channels[0].Channel)
+		assert.Equal(t, uint(2), channels[0].Videos)
+		assert.Equal(t, float64(37.5), channels[1].OverallPercent)
+		assert.Equal(t, float64(10*60), channels[2].Seconds)
+	}
+
+	require.NoError(t, store.End(ctx, "c", start.Add(time.Hour), start.Add(time.Hour+5*time.Minute)))
+	recent, err := store.RecentWatches(ctx, StatsFilter{From: start.Add(30 * time.Minute)}, now, 10)
+	require.NoError(t, err)
+	if assert.Len(t, recent, 1) {
+		assert.Equal(t, "c", recent[0].VideoID)
+		assert.Equal(t, float64(5*60), recent[0].Seconds)
+	}
+}
+
+func TestStoreEndOpen(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	start := time.Date(2025, time.March, 10, 20, 0, 0, 0, time.UTC)
+	// Left open by a previous process
+	require.NoError(t, store.Insert(ctx, WatchedVideo{VideoID: "live", Channel: "maple", StartedAt: start}))
+	require.NoError(t, store.Insert(ctx, WatchedVideo{VideoID: "a", Channel: "chocola", DurationSeconds: 5 * 60, StartedAt: start.Add(time.Hour)}))
+	now := start.Add(24 * time.Hour)
+
+	total, err := store.TotalWatchTime(ctx, StatsFilter{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, float64(24*60*60+5*60), total.Seconds)
+
+	ended, err := store.EndOpen(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), ended)
+
+	total, err = store.TotalWatchTime(ctx, StatsFilter{}, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, float64(liveActivityTimeout.Seconds()+5*60), total.Seconds)
+}
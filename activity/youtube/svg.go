@@ -0,0 +1,369 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/tifye/shigure/raster"
+)
+
+//go:embed templates/*.svg
+var templates embed.FS
+
+const (
+	DefaultSVGTheme = "dark"
+
+	MinSVGWidth = 64
+	MaxSVGWidth = 1280
+
+	// svgProgressInterval is how often the SVG of media that is
+	// playing is re-rendered for the progress bar to catch up.
+	svgProgressInterval = 15 * time.Second
+)
+
+var (
+	ErrUnknownSVGTheme = errors.New("unknown svg theme")
+	ErrInvalidSVGWidth = fmt.Errorf("svg width must be between %d and %d", MinSVGWidth, MaxSVGWidth)
+)
+
+// svgTemplate is a card template. Templates declare the size
+// they are drawn at, used as their viewBox, with a size template:
+//
+//	{{ define "size" }}320x180{{ end }}
+//
+// Text fields of the input are already HTML escaped.
+type svgTemplate struct {
+	tmpl   *template.Template
+	width  uint
+	height uint
+}
+
+var svgFuncs = template.FuncMap{
+	// truncate shortens escaped text to n runes
+	"truncate": func(n int, s string) string {
+		return html.EscapeString(raster.TruncateRunes(html.UnescapeString(s), n))
+	},
+	// progressWidth is the width of a progress bar of full width
+	"progressWidth": func(full float64, progress float64) string {
+		return fmt.Sprintf("%.2f", full*progress/100)
+	},
+}
+
+func parseSVGTemplate(name string, content string) (svgTemplate, error) {
+	tmpl, err := template.New(name).Funcs(svgFuncs).Parse(content)
+	if err != nil {
+		return svgTemplate{}, err
+	}
+
+	var size strings.Builder
+	if tmpl.Lookup("size") == nil {
+		return svgTemplate{}, fmt.Errorf("template %s does not define its size", name)
+	}
+	if err := tmpl.ExecuteTemplate(&size, "size", nil); err != nil {
+		return svgTemplate{}, err
+	}
+	var width, height uint
+	_, err = fmt.Sscanf(strings.TrimSpace(size.String()), "%dx%d", &width, &height)
+	if err != nil || width == 0 || height == 0 {
+		return svgTemplate{}, fmt.Errorf("template %s has invalid size %q", name, size.String())
+	}
+
+	return svgTemplate{tmpl: tmpl, width: width, height: height}, nil
+}
+
+// builtinSVGTemplates are the embedded templates by theme name.
+var builtinSVGTemplates = sync.OnceValue(func() map[string]svgTemplate {
+	templatesByName := map[string]svgTemplate{}
+	entries, err := fs.ReadDir(templates, "templates")
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range entries {
+		content, err := fs.ReadFile(templates, "templates/"+e.Name())
+		if err != nil {
+			panic(err)
+		}
+		name := strings.TrimSuffix(e.Name(), ".svg")
+		t, err := parseSVGTemplate(name, string(content))
+		if err != nil {
+			panic(err)
+		}
+		templatesByName[name] = t
+	}
+	return templatesByName
+})
+
+// svgTemplates returns the built-in templates along with those
+// of the template dir, which are read on every call so they
+// can be edited at runtime.
+func (c *ActivityClient) svgTemplates() (map[string]svgTemplate, error) {
+	templatesByName := make(map[string]svgTemplate)
+	for name, t := range builtinSVGTemplates() {
+		templatesByName[name] = t
+	}
+	if c.svgTemplateDir == "" {
+		return templatesByName, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(c.svgTemplateDir, "*.svg"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".svg")
+		t, err := parseSVGTemplate(name, string(content))
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %s", path, err)
+		}
+		templatesByName[name] = t
+	}
+	return templatesByName, nil
+}
+
+// SVGThemes returns the names of the built-in and custom themes.
+func (c *ActivityClient) SVGThemes() ([]string, error) {
+	templatesByName, err := c.svgTemplates()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(templatesByName))
+	for name := range templatesByName {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// cachedCard is a rendered card, as SVG or raster image.
+type cachedCard struct {
+	version    uint64
+	renderedAt time.Time
+	data       []byte
+	// width and height SVGs are drawn at, for them to be scaled
+	width  uint
+	height uint
+}
+
+// StreamSVG renders the current activity as an SVG card using
+// the named theme, scaled to width when it is not 0. Renders
+// are cached per theme until the activity changes, or the
+// progress bar falls behind playback, and scaled when served.
+func (c *ActivityClient) StreamSVG(ctx context.Context, out io.Writer, theme string, width uint) error {
+	if theme == "" {
+		theme = DefaultSVGTheme
+	}
+	if width != 0 && (width < MinSVGWidth || width > MaxSVGWidth) {
+		return ErrInvalidSVGWidth
+	}
+
+	now := time.Now()
+	c.mu.RLock()
+	activity, version := c.activityAt(now), c.version
+	c.mu.RUnlock()
+
+	key := "svg:" + theme
+	c.cardMu.Lock()
+	cached, ok := c.cardCache[key]
+	c.cardMu.Unlock()
+	if ok && cached.version == version && !progressStale(activity, cached.renderedAt, now) {
+		_, err := out.Write(scaleSVG(cached.data, cached.width, cached.height, width))
+		return err
+	}
+
+	templatesByName, err := c.svgTemplates()
+	if err != nil {
+		return err
+	}
+	t, ok := templatesByName[theme]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSVGTheme, theme)
+	}
+
+	c.logger.Debug("re-building youtube activity SVG", "theme", theme, "version", version)
+
+	var base64Image string
+	if thumbnail, ok := c.thumbnail(ctx, activity); ok {
+		base64Image = thumbnail.DataURL()
+	}
+
+	var buf bytes.Buffer
+	if err := renderActivitySVG(&buf, t, newSVGInput(activity, base64Image, t)); err != nil {
+		return err
+	}
+
+	c.cacheCard(key, cachedCard{version: version, renderedAt: now, data: buf.Bytes(), width: t.width, height: t.height})
+	_, err = out.Write(scaleSVG(buf.Bytes(), t.width, t.height, width))
+	return err
+}
+
+// svgSizeAttr matches the width or height attribute of an
+// element.
+var svgSizeAttr = regexp.MustCompile(`\s(width|height)="[^"]*"`)
+
+// scaleSVG sets the width and height attributes of the root
+// element of an SVG drawn at viewWidth by viewHeight for it to
+// be shown at width, keeping its aspect ratio. The SVG is
+// returned as is when width is 0.
+func scaleSVG(svg []byte, viewWidth uint, viewHeight uint, width uint) []byte {
+	if width == 0 || width == viewWidth {
+		return svg
+	}
+	start := bytes.Index(svg, []byte("<svg"))
+	if start < 0 {
+		return svg
+	}
+	end := bytes.IndexByte(svg[start:], '>')
+	if end < 0 {
+		return svg
+	}
+	end += start
+
+	size := map[string]uint{
+		"width":  width,
+		"height": max(1, (viewHeight*width+viewWidth/2)/viewWidth),
+	}
+	root := svgSizeAttr.ReplaceAllFunc(svg[start:end], func(attr []byte) []byte {
+		name := string(svgSizeAttr.FindSubmatch(attr)[1])
+		return fmt.Appendf(nil, ` %s="%d"`, name, size[name])
+	})
+
+	scaled := make([]byte, 0, len(svg)-(end-start)+len(root))
+	scaled = append(scaled, svg[:start]...)
+	scaled = append(scaled, root...)
+	return append(scaled, svg[end:]...)
+}
+
+// thumbnail returns the thumbnail of the activity, or a placeholder
+// when it could not be fetched. Not every provider has thumbnails,
+// e.g. local files over MPRIS, in which case ok is false.
+func (c *ActivityClient) thumbnail(ctx context.Context, a Activity) (t Thumbnail, ok bool) {
+	if a.ThumbnailUrl == "" {
+		return Thumbnail{}, false
+	}
+	t, err := c.thumbnails.Get(ctx, a.ThumbnailUrl)
+	if err != nil {
+		c.logger.Warn("get activity thumb", "err", err, "id", a.Id, "url", a.ThumbnailUrl)
+		return placeholderThumbnail(), true
+	}
+	return t, true
+}
+
+// cacheCard caches the card, dropping those of previous versions
+// which will never be served again. Cards are rendered without
+// holding cardMu, so one of a previous version finishing late is
+// not cached.
+func (c *ActivityClient) cacheCard(key string, card cachedCard) {
+	c.cardMu.Lock()
+	defer c.cardMu.Unlock()
+
+	for _, v := range c.cardCache {
+		if v.version > card.version {
+			return
+		}
+	}
+	for k, v := range c.cardCache {
+		if v.version != card.version {
+			delete(c.cardCache, k)
+		}
+	}
+	c.cardCache[key] = card
+}
+
+// progressStale reports whether the progress bar of an SVG
+// rendered at renderedAt has fallen behind playback.
+func progressStale(a Activity, renderedAt time.Time, now time.Time) bool {
+	if a.Id == placeholderVideoID || a.Paused || a.Live {
+		return false
+	}
+	return now.Sub(renderedAt) >= svgProgressInterval
+}
+
+type svgInput struct {
+	// Width and Height are the size the card is shown at,
+	// ViewWidth and ViewHeight the size it is drawn at. Cards
+	// are rendered at the size they are drawn at, the width and
+	// height attributes of their root element are scaled when
+	// they are served.
+	Width      uint
+	Height     uint
+	ViewWidth  uint
+	ViewHeight uint
+
+	// Title is the media title followed by the author.
+	Title        string
+	MediaTitle   string
+	Author       string
+	Base64Image  string
+	ExternalLink string
+	Watching     bool
+	// Live is shown instead of progress for live media.
+	Live bool
+
+	ShowProgress bool
+	// Progress in percent
+	Progress float64
+	Playing  bool
+	// Remaining is the seconds until playback ends
+	Remaining float64
+	// Position and Duration formatted as [h:]mm:ss
+	Position string
+	Duration string
+}
+
+func newSVGInput(a Activity, base64Image string, t svgTemplate) svgInput {
+	input := svgInput{
+		Width:      t.width,
+		Height:     t.height,
+		ViewWidth:  t.width,
+		ViewHeight: t.height,
+
+		Title:        html.EscapeString(fmt.Sprintf("%s - %s", a.Title, a.Author)),
+		MediaTitle:   html.EscapeString(a.Title),
+		Author:       html.EscapeString(a.Author),
+		Base64Image:  base64Image,
+		ExternalLink: html.EscapeString(a.Url),
+		Watching:     a.Id != placeholderVideoID,
+		Live:         a.Live && a.Id != placeholderVideoID,
+
+		ShowProgress: a.Id != placeholderVideoID && a.Duration > 0 && !a.Live,
+		Progress:     a.Progress * 100,
+		Playing:      !a.Paused && a.Position < a.Duration,
+		Position:     formatPlaybackTime(a.Position),
+		Duration:     formatPlaybackTime(a.Duration),
+	}
+	if input.Playing && a.Rate > 0 {
+		input.Remaining = (a.Duration - a.Position).Seconds() / a.Rate
+	}
+	return input
+}
+
+func renderActivitySVG(out io.Writer, t svgTemplate, input svgInput) error {
+	return t.tmpl.Execute(out, input)
+}
+
+func formatPlaybackTime(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,147 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestSVGTemplatesGolden(t *testing.T) {
+	activity := Activity{
+		Provider: ProviderYoutube,
+		Id:       "dQw4w9WgXcQ",
+		Title:    `Rick Astley - Never Gonna Give You Up (Official Music Video) <4K Remaster>`,
+		Author:   "Rick Astley",
+		Url:      "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		Duration: 3*time.Minute + 33*time.Second,
+		Position: time.Minute + 11*time.Second,
+		Rate:     1,
+		Progress: 71.0 / 213,
+	}
+
+	live := Activity{
+		Provider: ProviderYoutube,
+		Id:       "jfKfPfyJRdk",
+		Title:    "lofi hip hop radio 📚 beats to relax/study to",
+		Author:   "Lofi Girl",
+		Url:      "https://www.youtube.com/watch?v=jfKfPfyJRdk",
+		Live:     true,
+		Position: time.Hour,
+		Rate:     1,
+	}
+
+	for name, tmpl := range builtinSVGTemplates() {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			input := newSVGInput(activity, "data:image/jpeg;base64,AAAA", tmpl)
+			require.NoError(t, renderActivitySVG(&buf, tmpl, input))
+			assert.NotContains(t, buf.String(), "<4K")
+			assert.NotContains(t, buf.String(), "LIVE")
+			assertGolden(t, filepath.Join("testdata", name+".golden.svg"), buf.Bytes())
+
+			buf.Reset()
+			input = newSVGInput(live, "data:image/jpeg;base64,AAAA", tmpl)
+			require.NoError(t, renderActivitySVG(&buf, tmpl, input))
+			assert.Contains(t, buf.String(), "LIVE")
+			assertGolden(t, filepath.Join("testdata", name+".live.golden.svg"), buf.Bytes())
+		})
+	}
+}
+
+func assertGolden(t *testing.T, golden string, actual []byte) {
+	t.Helper()
+	if *update {
+		require.NoError(t, os.WriteFile(golden, actual, 0644))
+	}
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+}
+
+func TestSVGInputSize(t *testing.T) {
+	tmpl := builtinSVGTemplates()[DefaultSVGTheme]
+
+	input := newSVGInput(Activity{}, "", tmpl)
+	assert.Equal(t, uint(320), input.Width)
+	assert.Equal(t, uint(180), input.Height)
+	assert.Equal(t, uint(320), input.ViewWidth)
+}
+
+func TestScaleSVG(t *testing.T) {
+	svg := []byte(`<?xml version="1.0"?><svg width="320" height="180" viewBox="0 0 320 180"><rect width="100" height="2"/></svg>`)
+	assert.Equal(t,
+		`<?xml version="1.0"?><svg width="640" height="360" viewBox="0 0 320 180"><rect width="100" height="2"/></svg>`,
+		string(scaleSVG(svg, 320, 180, 640)))
+	assert.Equal(t, string(svg), string(scaleSVG(svg, 320, 180, 0)))
+}
+
+func TestParseSVGTemplate(t *testing.T) {
+	tmpl, err := parseSVGTemplate("custom", `{{ define "size" }} 100x20 {{ end }}<svg>{{ .Title }}</svg>`)
+	require.NoError(t, err)
+	assert.Equal(t, uint(100), tmpl.width)
+	assert.Equal(t, uint(20), tmpl.height)
+
+	_, err = parseSVGTemplate("custom", `<svg></svg>`)
+	assert.Error(t, err)
+	_, err = parseSVGTemplate("custom", `{{ define "size" }}wide{{ end }}<svg></svg>`)
+	assert.Error(t, err)
+}
+
+func TestStreamSVG(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plain.svg"), []byte(`{{ define "size" }}100x20{{ end }}<svg>{{ .MediaTitle }}</svg>`), 0644))
+
+	client := &ActivityClient{
+		logger:         log.New(io.Discard),
+		svgTemplateDir: dir,
+		cardCache:      map[string]cachedCard{},
+		currentActivity: Activity{
+			Id:       "a",
+			Title:    "<first>",
+			Duration: time.Minute,
+		},
+		playback: playback{
+			PlaybackState: PlaybackState{Rate: 1, Paused: true},
+			reportedAt:    time.Now(),
+			duration:      time.Minute,
+		},
+	}
+
+	themes, err := client.SVGThemes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"badge", "banner", "dark", "light", "plain"}, themes)
+
+	var buf bytes.Buffer
+	require.NoError(t, client.StreamSVG(context.Background(), &buf, "plain", 0))
+	assert.Equal(t, "<svg>&lt;first&gt;</svg>", buf.String())
+
+	// Cached until the activity changes
+	client.currentActivity.Title = "second"
+	buf.Reset()
+	require.NoError(t, client.StreamSVG(context.Background(), &buf, "plain", 0))
+	assert.Equal(t, "<svg>&lt;first&gt;</svg>", buf.String())
+
+	client.version++
+	buf.Reset()
+	require.NoError(t, client.StreamSVG(context.Background(), &buf, "plain", 0))
+	assert.Equal(t, "<svg>second</svg>", buf.String())
+
+	// Widths share the render of their theme
+	require.NoError(t, client.StreamSVG(context.Background(), io.Discard, "plain", MinSVGWidth))
+	require.NoError(t, client.StreamSVG(context.Background(), io.Discard, "plain", MaxSVGWidth))
+	assert.Len(t, client.cardCache, 1)
+
+	assert.ErrorIs(t, client.StreamSVG(context.Background(), io.Discard, "neon", 0), ErrUnknownSVGTheme)
+	assert.ErrorIs(t, client.StreamSVG(context.Background(), io.Discard, "plain", MaxSVGWidth+1), ErrInvalidSVGWidth)
+}
@@ -0,0 +1,365 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tifye/shigure/assert"
+	"github.com/tifye/shigure/raster"
+)
+
+const (
+	DefaultThumbnailCacheDir      = "./data/thumbnails"
+	DefaultThumbnailCacheMaxBytes = 64 << 20
+	DefaultThumbnailCacheTTL      = 7 * 24 * time.Hour
+
+	// Thumbnails are resized to cover the card at the density
+	// raster cards are drawn at.
+	thumbnailWidth  = imageCardWidth * imageScale
+	thumbnailHeight = imageCardHeight * imageScale
+
+	// maxThumbnailDownload is the largest thumbnail downloaded,
+	// larger responses are not thumbnails.
+	maxThumbnailDownload = 10 << 20
+	// maxThumbnailDimension is the widest and tallest thumbnail
+	// decoded, as small files can decode to huge images.
+	maxThumbnailDimension = 4096
+	thumbnailFetchTimeout = 10 * time.Second
+	thumbnailJPEGQuality  = 85
+
+	// thumbnailRetryInterval is how long a thumbnail that failed
+	// to be fetched is not fetched again, so that dead URLs don't
+	// hold up every card render.
+	thumbnailRetryInterval = time.Minute
+)
+
+var ErrNotAnImage = errors.New("not an image")
+
+// Thumbnail is an encoded image.
+type Thumbnail struct {
+	Data        []byte
+	ContentType string
+}
+
+func (t Thumbnail) DataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", t.ContentType, base64.StdEncoding.EncodeToString(t.Data))
+}
+
+func (t Thumbnail) Image() (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(t.Data))
+	return img, err
+}
+
+// placeholderThumbnail is shown when a thumbnail could not be
+// fetched, a play button on a dark background.
+var placeholderThumbnail = sync.OnceValue(func() Thumbnail {
+	img := image.NewRGBA(image.Rect(0, 0, thumbnailWidth, thumbnailHeight))
+	raster.FillRect(img, img.Bounds(), raster.MustParseHexColor("#272727"))
+
+	cx, cy, r := thumbnailWidth/2, thumbnailHeight/2, thumbnailHeight/8
+	play := raster.MustParseHexColor("#aaaaaa")
+	for y := cy - r; y <= cy+r; y++ {
+		// Right pointing triangle narrowing towards its tip
+		half := r - abs(y-cy)
+		for x := cx - r/2; x <= cx-r/2+2*half; x++ {
+			img.Set(x, y, play)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+		panic(err)
+	}
+	return Thumbnail{Data: buf.Bytes(), ContentType: "image/jpeg"}
+})
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+type thumbnailEntry struct {
+	// Hash of the resized thumbnail, which is stored in a file
+	// of that name so that URLs of the same image share it.
+	Hash      string    `json:"hash"`
+	Size      int64     `json:"size"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	UsedAt    time.Time `json:"usedAt"`
+}
+
+// ThumbnailCache fetches thumbnails, resizes them to the card
+// size and caches them on disk. Entries expire after the TTL and
+// the least recently used are evicted to keep the cache within
+// its size limit.
+type ThumbnailCache struct {
+	dir      string
+	maxBytes int64
+	ttl      time.Duration
+	client   *http.Client
+
+	mu sync.Mutex
+	// entries by URL
+	entries map[string]thumbnailEntry
+	// failures by URL, kept in memory only
+	failures map[string]thumbnailFailure
+}
+
+type thumbnailFailure struct {
+	err error
+	at  time.Time
+}
+
+func NewThumbnailCache(dir string, maxBytes int64, ttl time.Duration) (*ThumbnailCache, error) {
+	assert.AssertNotEmpty(dir)
+	assert.Assert(maxBytes > 0, "expected positive max bytes")
+	assert.Assert(ttl > 0, "expected positive ttl")
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	tc := &ThumbnailCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		client:   &http.Client{Timeout: thumbnailFetchTimeout},
+		entries:  map[string]thumbnailEntry{},
+		failures: map[string]thumbnailFailure{},
+	}
+
+	contents, err := os.ReadFile(tc.indexPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(contents) > 0 {
+		if err := json.Unmarshal(contents, &tc.entries); err != nil {
+			return nil, fmt.Errorf("parse thumbnail index: %s", err)
+		}
+	}
+	// Files may have been removed from under the index
+	for url, e := range tc.entries {
+		if _, err := os.Stat(tc.blobPath(e.Hash)); err != nil {
+			delete(tc.entries, url)
+		}
+	}
+
+	return tc, nil
+}
+
+func (tc *ThumbnailCache) indexPath() string {
+	return filepath.Join(tc.dir, "index.json")
+}
+
+func (tc *ThumbnailCache) blobPath(hash string) string {
+	return filepath.Join(tc.dir, hash+".jpg")
+}
+
+// Get returns the resized thumbnail at url, fetching it when it
+// is not cached or has expired. Failures are cached briefly, the
+// error of the last attempt is returned until it can be retried.
+func (tc *ThumbnailCache) Get(ctx context.Context, url string) (Thumbnail, error) {
+	now := time.Now()
+
+	tc.mu.Lock()
+	e, ok := tc.entries[url]
+	failure, failed := tc.failures[url]
+	tc.mu.Unlock()
+	if ok && now.Sub(e.FetchedAt) < tc.ttl {
+		data, err := os.ReadFile(tc.blobPath(e.Hash))
+		if err == nil {
+			tc.mu.Lock()
+			e.UsedAt = now
+			tc.entries[url] = e
+			tc.mu.Unlock()
+			return Thumbnail{Data: data, ContentType: "image/jpeg"}, nil
+		}
+	}
+
+	if failed && now.Sub(failure.at) < thumbnailRetryInterval {
+		return Thumbnail{}, failure.err
+	}
+
+	data, err := tc.fetch(ctx, url)
+	if err != nil {
+		tc.fail(now, url, err)
+		return Thumbnail{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	// Written while holding mu so that it is not evicted as
+	// a file without an entry
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if err := os.WriteFile(tc.blobPath(hash), data, 0644); err != nil {
+		return Thumbnail{}, err
+	}
+	delete(tc.failures, url)
+	tc.entries[url] = thumbnailEntry{
+		Hash:      hash,
+		Size:      int64(len(data)),
+		FetchedAt: now,
+		UsedAt:    now,
+	}
+	tc.evict(now)
+	if err := tc.save(); err != nil {
+		return Thumbnail{}, err
+	}
+
+	return Thumbnail{Data: data, ContentType: "image/jpeg"}, nil
+}
+
+// fail records that fetching url failed with err, dropping the
+// failures that can be retried by now.
+func (tc *ThumbnailCache) fail(now time.Time, url string, err error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	for u, f := range tc.failures {
+		if now.Sub(f.at) >= thumbnailRetryInterval {
+			delete(tc.failures, u)
+		}
+	}
+	tc.failures[url] = thumbnailFailure{err: err, at: now}
+}
+
+// fetch downloads the image at url and resizes it to cover the
+// thumbnail size, encoded as JPEG.
+func (tc *ThumbnailCache) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode > 299 {
+		return nil, fmt.Errorf("response failed with code %d", res.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxThumbnailDownload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxThumbnailDownload {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrNotAnImage, maxThumbnailDownload)
+	}
+	// Servers are not to be trusted with the content type,
+	// e.g. error pages served with a 200
+	if contentType := http.DetectContentType(body); !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("%w: %s", ErrNotAnImage, contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotAnImage, err)
+	}
+	if config.Width > maxThumbnailDimension || config.Height > maxThumbnailDimension {
+		return nil, fmt.Errorf("%w: %dx%d is larger than %dx%d", ErrNotAnImage, config.Width, config.Height, maxThumbnailDimension, maxThumbnailDimension)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotAnImage, err)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, thumbnailWidth, thumbnailHeight))
+	// JPEG has no transparency
+	raster.FillRect(dst, dst.Bounds(), color.Black)
+	raster.DrawCover(dst, dst.Bounds(), src)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// evict drops expired entries and the least recently used until
+// the thumbnails fit within maxBytes, the caller must hold mu.
+func (tc *ThumbnailCache) evict(now time.Time) {
+	for url, e := range tc.entries {
+		if now.Sub(e.FetchedAt) >= tc.ttl {
+			delete(tc.entries, url)
+		}
+	}
+
+	urls := make([]string, 0, len(tc.entries))
+	for url := range tc.entries {
+		urls = append(urls, url)
+	}
+	slices.SortFunc(urls, func(a, b string) int {
+		return tc.entries[a].UsedAt.Compare(tc.entries[b].UsedAt)
+	})
+	for _, url := range urls {
+		if tc.size() <= tc.maxBytes {
+			break
+		}
+		delete(tc.entries, url)
+	}
+
+	// Remove the files no longer referred to
+	referenced := make(map[string]bool, len(tc.entries))
+	for _, e := range tc.entries {
+		referenced[e.Hash+".jpg"] = true
+	}
+	files, err := filepath.Glob(filepath.Join(tc.dir, "*.jpg"))
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if !referenced[filepath.Base(f)] {
+			os.Remove(f)
+		}
+	}
+}
+
+// size is the total size of the stored thumbnails, which entries
+// of the same image share. The caller must hold mu.
+func (tc *ThumbnailCache) size() int64 {
+	var total int64
+	counted := make(map[string]bool, len(tc.entries))
+	for _, e := range tc.entries {
+		if !counted[e.Hash] {
+			counted[e.Hash] = true
+			total += e.Size
+		}
+	}
+	return total
+}
+
+// save persists the index, the caller must hold mu.
+func (tc *ThumbnailCache) save() error {
+	contents, err := json.MarshalIndent(tc.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempPath := tc.indexPath() + "_temp"
+	if err := os.WriteFile(tempPath, contents, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, tc.indexPath())
+}
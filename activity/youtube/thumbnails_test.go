@@ -0,0 +1,144 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tifye/shigure/raster"
+)
+
+func newThumbnailServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	img := image.NewRGBA(image.Rect(0, 0, 1280, 720))
+	raster.FillRect(img, img.Bounds(), raster.MustParseHexColor("#ff0000"))
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, img))
+	// Small to download but too large to decode
+	var huge bytes.Buffer
+	require.NoError(t, png.Encode(&huge, image.NewGray(image.Rect(0, 0, maxThumbnailDimension+1, 1))))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch {
+		case strings.HasSuffix(r.URL.Path, ".html"):
+			// Error page served as an image
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("<html><body>rate limited</body></html>"))
+		case strings.HasSuffix(r.URL.Path, "huge.png"):
+			w.Write(huge.Bytes())
+		case strings.HasSuffix(r.URL.Path, ".png"):
+			w.Write(encoded.Bytes())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestThumbnailCacheGet(t *testing.T) {
+	srv, requests := newThumbnailServer(t)
+	dir := t.TempDir()
+	tc, err := NewThumbnailCache(dir, DefaultThumbnailCacheMaxBytes, time.Hour)
+	require.NoError(t, err)
+
+	thumb, err := tc.Get(context.Background(), srv.URL+"/a.png")
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", thumb.ContentType)
+	img, format, err := image.DecodeConfig(bytes.NewReader(thumb.Data))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, thumbnailWidth, img.Width)
+	assert.Equal(t, thumbnailHeight, img.Height)
+
+	// Served from the cache, the same image of another URL shares the file
+	_, err = tc.Get(context.Background(), srv.URL+"/a.png")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+	_, err = tc.Get(context.Background(), srv.URL+"/b.png")
+	require.NoError(t, err)
+	files, err := filepath.Glob(filepath.Join(dir, "*.jpg"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	// The index survives restarts
+	tc, err = NewThumbnailCache(dir, DefaultThumbnailCacheMaxBytes, time.Hour)
+	require.NoError(t, err)
+	_, err = tc.Get(context.Background(), srv.URL+"/a.png")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+
+	_, err = tc.Get(context.Background(), srv.URL+"/error.html")
+	assert.ErrorIs(t, err, ErrNotAnImage)
+	_, err = tc.Get(context.Background(), srv.URL+"/huge.png")
+	assert.ErrorIs(t, err, ErrNotAnImage)
+	_, err = tc.Get(context.Background(), srv.URL+"/missing")
+	assert.Error(t, err)
+
+	// Failures are not retried right away
+	before := requests.Load()
+	_, err = tc.Get(context.Background(), srv.URL+"/missing")
+	assert.Error(t, err)
+	assert.Equal(t, before, requests.Load())
+}
+
+func TestThumbnailCacheEvict(t *testing.T) {
+	dir := t.TempDir()
+	tc, err := NewThumbnailCache(dir, 10, time.Hour)
+	require.NoError(t, err)
+
+	now := time.Now()
+	for i, name := range []string{"a", "b", "c"} {
+		require.NoError(t, os.WriteFile(tc.blobPath(name), []byte("12345"), 0644))
+		tc.entries[name] = thumbnailEntry{
+			Hash:      name,
+			Size:      5,
+			FetchedAt: now,
+			UsedAt:    now.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	tc.entries["expired"] = thumbnailEntry{Hash: "c", Size: 5, FetchedAt: now.Add(-2 * time.Hour), UsedAt: now.Add(time.Hour)}
+
+	tc.evict(now)
+	assert.Len(t, tc.entries, 2)
+	assert.NotContains(t, tc.entries, "a")
+	assert.NotContains(t, tc.entries, "expired")
+	_, err = os.Stat(tc.blobPath("a"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(tc.blobPath("c"))
+	assert.NoError(t, err)
+}
+
+func TestStreamSVGThumbnailFallback(t *testing.T) {
+	srv, _ := newThumbnailServer(t)
+	tc, err := NewThumbnailCache(t.TempDir(), DefaultThumbnailCacheMaxBytes, time.Hour)
+	require.NoError(t, err)
+
+	client := &ActivityClient{
+		logger:     log.New(io.Discard),
+		cardCache:  map[string]cachedCard{},
+		thumbnails: tc,
+		currentActivity: Activity{
+			Id:           "a",
+			ThumbnailUrl: srv.URL + "/missing",
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, client.StreamSVG(context.Background(), &buf, "", 0))
+	assert.Contains(t, buf.String(), placeholderThumbnail().DataURL())
+}
@@ -0,0 +1,84 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tifye/shigure/assert"
+)
+
+const twitchAPIURL = "https://api.twitch.tv/helix"
+
+// TwitchProvider resolves Twitch VOD ids using the Helix API.
+type TwitchProvider struct {
+	clientID    string
+	accessToken string
+	baseURL     string
+	client      *http.Client
+}
+
+// NewTwitchProvider creates a provider authenticating with an
+// app access token of the application clientID.
+func NewTwitchProvider(clientID string, accessToken string) *TwitchProvider {
+	assert.AssertNotEmpty(clientID)
+	assert.AssertNotEmpty(accessToken)
+	return &TwitchProvider{
+		clientID:    clientID,
+		accessToken: accessToken,
+		baseURL:     twitchAPIURL,
+		client:      providerClient,
+	}
+}
+
+func (p *TwitchProvider) Name() string {
+	return ProviderTwitch
+}
+
+type twitchVideosResponse struct {
+	Data []struct {
+		Id           string `json:"id"`
+		Title        string `json:"title"`
+		UserName     string `json:"user_name"`
+		Url          string `json:"url"`
+		ThumbnailUrl string `json:"thumbnail_url"`
+		// Go duration syntax, e.g. 1h2m3s
+		Duration string `json:"duration"`
+	} `json:"data"`
+}
+
+func (p *TwitchProvider) Resolve(ctx context.Context, videoID string) (Activity, error) {
+	header := http.Header{}
+	header.Set("Client-Id", p.clientID)
+	header.Set("Authorization", "Bearer "+p.accessToken)
+
+	var resp twitchVideosResponse
+	err := getJSON(ctx, p.client, p.baseURL+"/videos?id="+url.QueryEscape(videoID), header, &resp)
+	if err != nil {
+		return Activity{}, err
+	}
+	if len(resp.Data) == 0 {
+		return Activity{}, fmt.Errorf("%w: %s", ErrMediaNotFound, videoID)
+	}
+
+	video := resp.Data[0]
+	duration, err := time.ParseDuration(video.Duration)
+	if err != nil {
+		return Activity{}, fmt.Errorf("parse duration %q: %s", video.Duration, err)
+	}
+	// Thumbnail URLs are templates of the requested size
+	thumbnail := strings.NewReplacer("%{width}", "640", "%{height}", "360").Replace(video.ThumbnailUrl)
+
+	return Activity{
+		Provider:     ProviderTwitch,
+		Id:           mediaID(video.Id),
+		Title:        video.Title,
+		Author:       video.UserName,
+		Url:          video.Url,
+		ThumbnailUrl: thumbnail,
+		Duration:     duration,
+	}, nil
+}
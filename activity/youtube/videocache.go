@@ -0,0 +1,151 @@
+package youtube
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/tifye/shigure/assert"
+)
+
+const (
+	DefaultVideoCacheSize = 512
+
+	// videoCacheTTL is how long video resources are used before
+	// being fetched again, in case their title changed.
+	videoCacheTTL = 30 * 24 * time.Hour
+)
+
+// videoIDPattern matches ids safe to use as file names, which all
+// YouTube video ids are.
+var videoIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// VideoCacheStats are the counts of lookups of video resources
+// since start up.
+type VideoCacheStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+type cachedVideo struct {
+	Resource  YoutubeVideoResource `json:"resource"`
+	FetchedAt time.Time            `json:"fetchedAt"`
+}
+
+// videoCache keeps the most recently used video resources in
+// memory and all of them on disk, so that watching a video again
+// does not cost quota, even after a restart.
+type videoCache struct {
+	dir  string
+	size int
+
+	mu    sync.Mutex
+	order *list.List
+	// elements of order by video id
+	entries map[string]*list.Element
+	hits    int64
+	misses  int64
+}
+
+func newVideoCache(dir string, size int) (*videoCache, error) {
+	assert.AssertNotEmpty(dir)
+	assert.Assert(size > 0, "expected positive video cache size")
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &videoCache{
+		dir:     dir,
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}, nil
+}
+
+func (vc *videoCache) path(id string) string {
+	return filepath.Join(vc.dir, id+".json")
+}
+
+func (vc *videoCache) get(now time.Time, id string) (YoutubeVideoResource, bool) {
+	if !videoIDPattern.MatchString(id) {
+		return YoutubeVideoResource{}, false
+	}
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if el, ok := vc.entries[id]; ok {
+		v := el.Value.(cachedVideo)
+		if now.Sub(v.FetchedAt) < videoCacheTTL {
+			vc.order.MoveToFront(el)
+			vc.hits++
+			return v.Resource, true
+		}
+		vc.order.Remove(el)
+		delete(vc.entries, id)
+	}
+
+	var v cachedVideo
+	contents, err := os.ReadFile(vc.path(id))
+	if err != nil || json.Unmarshal(contents, &v) != nil || now.Sub(v.FetchedAt) >= videoCacheTTL {
+		vc.misses++
+		return YoutubeVideoResource{}, false
+	}
+	vc.add(id, v)
+	vc.hits++
+	return v.Resource, true
+}
+
+func (vc *videoCache) put(now time.Time, resource YoutubeVideoResource) error {
+	if !videoIDPattern.MatchString(resource.Id) {
+		return errors.New("invalid video id")
+	}
+
+	v := cachedVideo{Resource: resource, FetchedAt: now}
+	contents, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if el, ok := vc.entries[resource.Id]; ok {
+		vc.order.Remove(el)
+		delete(vc.entries, resource.Id)
+	}
+	vc.add(resource.Id, v)
+
+	tempPath := vc.path(resource.Id) + "_temp"
+	if err := os.WriteFile(tempPath, contents, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, vc.path(resource.Id))
+}
+
+// add puts v in front evicting the least recently used from
+// memory, the caller must hold mu.
+func (vc *videoCache) add(id string, v cachedVideo) {
+	vc.entries[id] = vc.order.PushFront(v)
+	for vc.order.Len() > vc.size {
+		oldest := vc.order.Back()
+		vc.order.Remove(oldest)
+		delete(vc.entries, oldest.Value.(cachedVideo).Resource.Id)
+	}
+}
+
+func (vc *videoCache) stats() VideoCacheStats {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	return VideoCacheStats{
+		Entries: vc.order.Len(),
+		Hits:    vc.hits,
+		Misses:  vc.misses,
+	}
+}
@@ -1,12 +1,18 @@
 package api
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/labstack/echo/v4"
 	"github.com/tifye/shigure/activity/code"
 	"github.com/tifye/shigure/activity/youtube"
+	"github.com/tifye/shigure/raster"
 )
 
 func handlePostYoutubeActivity(logger *log.Logger, ac *youtube.ActivityClient) echo.HandlerFunc {
@@ -21,7 +27,119 @@ func handlePostYoutubeActivity(logger *log.Logger, ac *youtube.ActivityClient) e
 
 		err := ac.SetYoutubeActivity(c.Request().Context(), req.VideoId)
 		if err != nil {
-			logger.Error("failed to fetch youtube video", "videoId", req.VideoId, "err", err)
+			status := mediaErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				logger.Error("failed to fetch youtube video", "videoId", req.VideoId, "err", err)
+				return c.NoContent(status)
+			}
+			logger.Warn("failed to fetch youtube video", "videoId", req.VideoId, "err", err)
+			return c.String(status, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, ac.Activity())
+	}
+}
+
+// handlePostMediaActivity sets the current activity to the media
+// with the id, or URL for oembed, resolved by the provider.
+func handlePostMediaActivity(logger *log.Logger, ac *youtube.ActivityClient) echo.HandlerFunc {
+	type request struct {
+		Provider string `param:"provider"`
+		Id       string `json:"id"`
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		if len(req.Id) == 0 || len(req.Id) > 2048 {
+			return c.String(http.StatusBadRequest, "invalid id")
+		}
+
+		err := ac.SetMediaActivity(c.Request().Context(), req.Provider, req.Id)
+		if err != nil {
+			status := mediaErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				logger.Error("failed to resolve media", "provider", req.Provider, "id", req.Id, "err", err)
+				return c.NoContent(status)
+			}
+			if status != http.StatusNotFound {
+				logger.Warn("failed to resolve media", "provider", req.Provider, "id", req.Id, "err", err)
+			}
+			return c.String(status, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, ac.Activity())
+	}
+}
+
+// mediaErrorStatus is the status of responses to failures to
+// resolve media, which are mostly the fault of the provider.
+func mediaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, youtube.ErrUnknownProvider), errors.Is(err, youtube.ErrMediaNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, youtube.ErrQuotaExhausted):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, youtube.ErrProviderUnavailable):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// handlePostMPRISActivity sets the current activity to the track
+// pushed by a local MPRIS agent.
+func handlePostMPRISActivity(logger *log.Logger, ac *youtube.ActivityClient) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req youtube.MPRISMetadata
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		activity, err := req.Activity()
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		ac.SetActivity(c.Request().Context(), activity)
+		return c.JSON(http.StatusOK, ac.Activity())
+	}
+}
+
+// handlePostMediaPlayback updates the playback of the current
+// activity, position is in seconds and rate defaults to 1.
+func handlePostMediaPlayback(logger *log.Logger, ac *youtube.ActivityClient) echo.HandlerFunc {
+	type request struct {
+		Id       string   `json:"id"`
+		Position float64  `json:"position"`
+		Rate     *float64 `json:"rate"`
+		Paused   bool     `json:"paused"`
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		state := youtube.PlaybackState{
+			Position: time.Duration(req.Position * float64(time.Second)),
+			Rate:     1,
+			Paused:   req.Paused,
+		}
+		if req.Rate != nil {
+			state.Rate = *req.Rate
+		}
+
+		err := ac.SetPlayback(req.Id, state)
+		if err != nil {
+			switch {
+			case errors.Is(err, youtube.ErrInvalidPlayback):
+				return c.String(http.StatusBadRequest, err.Error())
+			case errors.Is(err, youtube.ErrPlaybackMismatch):
+				return c.String(http.StatusConflict, err.Error())
+			}
+			logger.Error("set media playback", "err", err)
 			return c.NoContent(http.StatusInternalServerError)
 		}
 
@@ -29,24 +147,79 @@ func handlePostYoutubeActivity(logger *log.Logger, ac *youtube.ActivityClient) e
 	}
 }
 
-func handleGetYoutubeActivity(ac *youtube.ActivityClient) echo.HandlerFunc {
+func handleGetYoutubeActivity(ac *youtube.ActivityClient, policy CachePolicy) echo.HandlerFunc {
 	return func(c echo.Context) error {
+		version, modifiedAt := ac.Version()
+		if checkNotModified(c, policy, version, "", modifiedAt) {
+			return notModified(c)
+		}
 		return c.JSON(http.StatusOK, ac.Activity())
 	}
 }
 
-func handleGetYoutubeActivitySVG(logger *log.Logger, ac *youtube.ActivityClient) echo.HandlerFunc {
+// handleGetYoutubeActivitySVG renders the activity card using
+// the theme query param, scaled to the width query param.
+func handleGetYoutubeActivitySVG(logger *log.Logger, ac *youtube.ActivityClient, policy CachePolicy) echo.HandlerFunc {
+	type request struct {
+		Theme string `query:"theme"`
+		Width uint   `query:"width"`
+	}
 	return func(c echo.Context) error {
-		c.Response().Header().Set(echo.HeaderContentType, "image/svg+xml")
-		c.Response().Header().Add("Cache-Control", "no-cache")
-		c.Response().WriteHeader(http.StatusOK)
-		err := ac.StreamSVG(c.Request().Context(), c.Response())
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return c.String(http.StatusBadRequest, "invalid width")
+		}
+
+		// Versioned before rendering so that a card is never
+		// older than its validators
+		version, modifiedAt := ac.Version()
+		var buf bytes.Buffer
+		err := ac.StreamSVG(c.Request().Context(), &buf, req.Theme, req.Width)
 		if err != nil {
+			if errors.Is(err, youtube.ErrUnknownSVGTheme) || errors.Is(err, youtube.ErrInvalidSVGWidth) {
+				return c.String(http.StatusBadRequest, err.Error())
+			}
 			logger.Errorf("Get SVG: %s", err)
 			return c.NoContent(http.StatusInternalServerError)
 		}
 
-		return nil
+		variant := fmt.Sprintf("svg-%s-%d", req.Theme, req.Width)
+		if checkNotModified(c, policy, version, variant, modifiedAt) {
+			return notModified(c)
+		}
+		return c.Blob(http.StatusOK, "image/svg+xml", buf.Bytes())
+	}
+}
+
+// handleGetYoutubeActivityImage renders the activity card as a
+// PNG, or WebP with the format query param.
+func handleGetYoutubeActivityImage(logger *log.Logger, ac *youtube.ActivityClient, policy CachePolicy) echo.HandlerFunc {
+	type request struct {
+		Format string `query:"format"`
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		format, err := raster.ParseFormat(req.Format)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		version, modifiedAt := ac.Version()
+		if checkNotModified(c, policy, version, string(format), modifiedAt) {
+			return notModified(c)
+		}
+
+		var buf bytes.Buffer
+		if err := ac.StreamImage(c.Request().Context(), &buf, format); err != nil {
+			logger.Errorf("Get image: %s", err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.Blob(http.StatusOK, format.ContentType(), buf.Bytes())
 	}
 }
 
@@ -71,7 +244,7 @@ func handlePostMarkRepoRedacted(logger *log.Logger, ac *code.ActivityClient) ech
 			return c.NoContent(http.StatusBadRequest)
 		}
 
-		err := ac.MarkRepoRedacted(req.Repo)
+		err := ac.MarkRepoRedacted(c.Request().Context(), req.Repo)
 		if err != nil {
 			logger.Error("Failed to mark repo redacted", "error", err)
 			return c.NoContent(http.StatusInternalServerError)
@@ -81,7 +254,126 @@ func handlePostMarkRepoRedacted(logger *log.Logger, ac *code.ActivityClient) ech
 	}
 }
 
-func handlePostVSCodeActivity(_ *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+// handleGetRedactionRules lists the rules of the
+// authenticated user.
+func handleGetRedactionRules(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		rules, err := ac.RedactionRules(authenticatedUser(c))
+		if err != nil {
+			logger.Error("get redaction rules", "err", err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.JSON(http.StatusOK, rules)
+	}
+}
+
+// handlePutRedactionRule creates a new rule of the authenticated
+// user when there is no id path param and otherwise replaces the
+// rule with that id.
+func handlePutRedactionRule(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+	type request struct {
+		ID string `param:"id"`
+		code.RedactionRule
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		req.RedactionRule.ID = req.ID
+
+		rule, err := ac.PutRedactionRule(c.Request().Context(), authenticatedUser(c), req.RedactionRule)
+		if err != nil {
+			switch {
+			case errors.Is(err, code.ErrInvalidRedactionRule):
+				return c.String(http.StatusBadRequest, err.Error())
+			case errors.Is(err, code.ErrRedactionRuleNotFound):
+				return c.NoContent(http.StatusNotFound)
+			}
+			logger.Error("put redaction rule", "err", err, "rule", req.RedactionRule)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.JSON(http.StatusOK, rule)
+	}
+}
+
+func handleDeleteRedactionRule(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+	type request struct {
+		ID string `param:"id"`
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		err := ac.RemoveRedactionRule(authenticatedUser(c), req.ID)
+		if err != nil {
+			if errors.Is(err, code.ErrRedactionRuleNotFound) {
+				return c.NoContent(http.StatusNotFound)
+			}
+			logger.Error("remove redaction rule", "err", err, "id", req.ID)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+func handleGetRepositoryAliases(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, ac.RepositoryAliases())
+	}
+}
+
+// handlePutRepositoryAlias adds an alias, or replaces the alias
+// of the same repository, and merges the stored activity.
+func handlePutRepositoryAlias(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req code.RepositoryAlias
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		alias, err := ac.PutRepositoryAlias(c.Request().Context(), req)
+		if err != nil {
+			if errors.Is(err, code.ErrInvalidRepositoryAlias) {
+				return c.String(http.StatusBadRequest, err.Error())
+			}
+			logger.Error("put repository alias", "err", err, "alias", req)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.JSON(http.StatusOK, alias)
+	}
+}
+
+func handleDeleteRepositoryAlias(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+	type request struct {
+		From string `query:"from"`
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		err := ac.RemoveRepositoryAlias(req.From)
+		if err != nil {
+			if errors.Is(err, code.ErrRepositoryAliasNotFound) {
+				return c.NoContent(http.StatusNotFound)
+			}
+			logger.Error("remove repository alias", "err", err, "from", req.From)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+func handlePostVSCodeActivity(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
 	type request code.VSCodeActivity
 	return func(c echo.Context) error {
 		var req request
@@ -89,14 +381,157 @@ func handlePostVSCodeActivity(_ *log.Logger, ac *code.ActivityClient) echo.Handl
 			return err
 		}
 
-		ac.SetActivity(c.Request().Context(), code.VSCodeActivity(req))
+		err := ac.SetActivity(c.Request().Context(), authenticatedUser(c), code.VSCodeActivity(req).EditorActivity())
+		if err != nil {
+			logger.Error("set vscode activity", "err", err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
 		return c.NoContent(http.StatusOK)
 	}
 }
 
-func handleGetVSCodeActivity(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+func handlePostEditorActivity(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+	type request code.EditorActivity
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		if len(req.Editor) == 0 {
+			return c.String(http.StatusBadRequest, "missing editor")
+		}
+
+		err := ac.SetActivity(c.Request().Context(), authenticatedUser(c), code.EditorActivity(req))
+		if err != nil {
+			logger.Error("set editor activity", "err", err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+func handleGetVSCodeActivity(logger *log.Logger, ac *code.ActivityClient, policy CachePolicy) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		logger.Debug("get vscode activity")
+		version, modifiedAt := ac.Version()
+		if checkNotModified(c, policy, version, "", modifiedAt) {
+			return notModified(c)
+		}
+		return c.JSON(http.StatusOK, ac.Activity())
+	}
+}
+
+func handleGetEditorActivity(ac *code.ActivityClient, policy CachePolicy) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		version, modifiedAt := ac.Version()
+		if checkNotModified(c, policy, version, "", modifiedAt) {
+			return notModified(c)
+		}
 		return c.JSON(http.StatusOK, ac.Activity())
 	}
 }
+
+// handleGetVSCodeActivityHistory returns a page of the timeline of
+// the owner's activity. Besides cursor and limit, the query params
+// of the code stats narrow down the timeline.
+func handleGetVSCodeActivityHistory(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+	type request struct {
+		Cursor string `query:"cursor"`
+		Limit  uint   `query:"limit"`
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+			return c.String(http.StatusBadRequest, "invalid limit")
+		}
+		if req.Limit > code.MaxHistoryLimit {
+			return c.String(http.StatusBadRequest, "limit too large")
+		}
+
+		filter, err := bindStatsFilter(c, ac)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		history, err := ac.History(c.Request().Context(), filter, req.Cursor, req.Limit)
+		if err != nil {
+			if errors.Is(err, code.ErrInvalidCursor) {
+				return c.String(http.StatusBadRequest, err.Error())
+			}
+			logger.Error("get activity history", "err", err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.JSON(http.StatusOK, history)
+	}
+}
+
+func handleGetVSCodeActivitySVG(logger *log.Logger, ac *code.ActivityClient, policy CachePolicy) echo.HandlerFunc {
+	type request struct {
+		Theme string `query:"theme"`
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		if req.Theme != "" && !slices.Contains(code.SVGThemes(), req.Theme) {
+			return c.String(http.StatusBadRequest, "unknown theme")
+		}
+
+		version, modifiedAt := ac.Version()
+		if checkNotModified(c, policy, version, "svg-"+req.Theme, modifiedAt) {
+			return notModified(c)
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, "image/svg+xml")
+		c.Response().WriteHeader(http.StatusOK)
+		err := ac.StreamSVG(c.Response(), req.Theme)
+		if err != nil {
+			logger.Errorf("Get vscode SVG: %s", err)
+		}
+
+		return nil
+	}
+}
+
+// handleGetVSCodeActivityImage renders the activity card as a
+// PNG, or WebP with the format query param, using the theme
+// query param.
+func handleGetVSCodeActivityImage(logger *log.Logger, ac *code.ActivityClient, policy CachePolicy) echo.HandlerFunc {
+	type request struct {
+		Theme  string `query:"theme"`
+		Format string `query:"format"`
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		if req.Theme != "" && !slices.Contains(code.SVGThemes(), req.Theme) {
+			return c.String(http.StatusBadRequest, "unknown theme")
+		}
+		format, err := raster.ParseFormat(req.Format)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		version, modifiedAt := ac.Version()
+		if checkNotModified(c, policy, version, req.Theme+"-"+string(format), modifiedAt) {
+			return notModified(c)
+		}
+
+		var buf bytes.Buffer
+		if err := ac.StreamImage(&buf, req.Theme, format); err != nil {
+			logger.Errorf("Get vscode image: %s", err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.Blob(http.StatusOK, format.ContentType(), buf.Bytes())
+	}
+}
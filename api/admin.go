@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/tifye/shigure/activity/youtube"
+	"github.com/tifye/shigure/assert"
+)
+
+// handleGetYoutubeQuota shows the YouTube Data API quota used
+// today and how well the video cache spares it.
+func handleGetYoutubeQuota(logger *log.Logger, provider *youtube.YoutubeProvider) echo.HandlerFunc {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(provider)
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, provider.Status())
+	}
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
@@ -13,61 +15,163 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/pquerna/otp/totp"
 	"github.com/spf13/viper"
+	"github.com/tifye/shigure/activity/code"
 	"github.com/tifye/shigure/assert"
 )
 
-func verifyToken(c echo.Context, config *viper.Viper) error {
+const userContextKey = "user"
+
+// verifyToken returns the user the bearer token was issued to.
+func verifyToken(c echo.Context, config *viper.Viper) (string, error) {
 	authHeader := c.Request().Header.Get("Authorization")
 	if authHeader == "" {
-		return fmt.Errorf("missing Authorization header")
+		return "", fmt.Errorf("missing Authorization header")
 	}
 
+	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
+	return parseUserToken(tokenStr, config)
+}
+
+// parseUserToken verifies tokenStr and returns its subject. Tokens
+// issued before shigure tracked multiple users have no subject and
+// belong to the owner.
+func parseUserToken(tokenStr string, config *viper.Viper) (string, error) {
 	signingKey := config.GetString("JWT_SIGNING_KEY")
 	assert.AssertNotEmpty(signingKey)
 
-	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-	_, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) {
 		return []byte(signingKey), nil
 	}, jwt.WithExpirationRequired())
-	return err
+	if err != nil {
+		return "", err
+	}
+
+	user, err := token.Claims.GetSubject()
+	if err != nil {
+		return "", err
+	}
+	if user == "" {
+		return code.OwnerUser, nil
+	}
+	if !code.ValidUser(user) {
+		return "", fmt.Errorf("%w: invalid subject", jwt.ErrTokenInvalidClaims)
+	}
+	return user, nil
+}
+
+func respondTokenError(c echo.Context, logger *log.Logger, err error) error {
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return c.String(http.StatusUnauthorized, "token expired")
+	}
+
+	if errors.Is(err, jwt.ErrTokenMalformed) {
+		return c.String(http.StatusBadRequest, "malformed token")
+	}
+
+	logger.Debug("token parse fail", "err", err)
+	return c.NoContent(http.StatusBadRequest)
 }
 
+// requireAuthMiddleware only lets through requests with
+// a token of the owner.
 func requireAuthMiddleware(logger *log.Logger, config *viper.Viper) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			err := verifyToken(c, config)
+			user, err := verifyToken(c, config)
 			if err != nil {
-				if errors.Is(err, jwt.ErrTokenExpired) {
-					return c.String(http.StatusUnauthorized, "token expired")
-				}
+				return respondTokenError(c, logger, err)
+			}
+
+			if user != code.OwnerUser {
+				return c.NoContent(http.StatusForbidden)
+			}
 
-				if errors.Is(err, jwt.ErrTokenMalformed) {
-					return c.String(http.StatusBadRequest, "malformed token")
-				}
+			c.Set(userContextKey, user)
+			return next(c)
+		}
+	}
+}
 
-				logger.Debug("token parse fail", "err", err)
-				return c.NoContent(http.StatusBadRequest)
+// requireUserAuthMiddleware lets through requests with a token of
+// any user, available to handlers through authenticatedUser.
+func requireUserAuthMiddleware(logger *log.Logger, config *viper.Viper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, err := verifyToken(c, config)
+			if err != nil {
+				return respondTokenError(c, logger, err)
 			}
 
+			c.Set(userContextKey, user)
 			return next(c)
 		}
 	}
 }
 
-func handlePostVerifyToken(logger *log.Logger, config *viper.Viper) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		err := verifyToken(c, config)
-		if err != nil {
-			if errors.Is(err, jwt.ErrTokenExpired) {
-				return c.String(http.StatusUnauthorized, "token expired")
+// authenticatedUser is the user set by one of the
+// auth middlewares.
+func authenticatedUser(c echo.Context) string {
+	user, _ := c.Get(userContextKey).(string)
+	assert.AssertNotEmpty(user)
+	return user
+}
+
+// requireWakaTimeAuthMiddleware accepts either a user token or the
+// WakaTime API key of the owner, as sent by WakaTime plugins in a
+// basic Authorization header or the api_key query param. Users can
+// configure their token as the API key of their plugin.
+func requireWakaTimeAuthMiddleware(logger *log.Logger, config *viper.Viper) echo.MiddlewareFunc {
+	requireUser := requireUserAuthMiddleware(logger, config)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		nextWithUser := requireUser(next)
+		return func(c echo.Context) error {
+			key, ok := wakaTimeAPIKey(c)
+			if !ok {
+				return nextWithUser(c)
 			}
 
-			if errors.Is(err, jwt.ErrTokenMalformed) {
-				return c.String(http.StatusBadRequest, "malformed token")
+			if user, err := parseUserToken(key, config); err == nil {
+				c.Set(userContextKey, user)
+				return next(c)
 			}
 
-			logger.Debug("token parse fail", "err", err)
-			return c.NoContent(http.StatusBadRequest)
+			apiKey := config.GetString("WAKATIME_API_KEY")
+			if apiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+				return c.NoContent(http.StatusUnauthorized)
+			}
+
+			c.Set(userContextKey, code.OwnerUser)
+			return next(c)
+		}
+	}
+}
+
+func wakaTimeAPIKey(c echo.Context) (string, bool) {
+	if key := c.QueryParam("api_key"); key != "" {
+		return key, true
+	}
+
+	authHeader := c.Request().Header.Get("Authorization")
+	encoded, ok := strings.CutPrefix(authHeader, "Basic ")
+	if !ok {
+		return "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+
+	// Some plugins send the key as a username without a password
+	key, _, _ := strings.Cut(string(decoded), ":")
+	return key, true
+}
+
+func handlePostVerifyToken(logger *log.Logger, config *viper.Viper) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		_, err := verifyToken(c, config)
+		if err != nil {
+			return respondTokenError(c, logger, err)
 		}
 
 		return c.NoContent(http.StatusOK)
@@ -90,6 +194,7 @@ func handleGetToken(logger *log.Logger, config *viper.Viper) echo.HandlerFunc {
 		}
 
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Subject:   code.OwnerUser,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		})
@@ -105,9 +210,28 @@ func handleGetToken(logger *log.Logger, config *viper.Viper) echo.HandlerFunc {
 	}
 }
 
+// handleGetGenerateToken issues a long lived token for the owner,
+// or for the user query param such as for a team member to ingest
+// their activity with.
 func handleGetGenerateToken(logger *log.Logger, config *viper.Viper) echo.HandlerFunc {
+	type request struct {
+		User string `query:"user"`
+	}
 	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		if req.User == "" {
+			req.User = code.OwnerUser
+		}
+		if !code.ValidUser(req.User) {
+			return c.String(http.StatusBadRequest, "invalid user")
+		}
+
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Subject:   req.User,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 30)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		})
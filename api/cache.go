@@ -0,0 +1,98 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CachePolicy is how long responses of an endpoint may be used
+// by caches before they have to be revalidated. The zero policy
+// makes caches revalidate every time, which is cheap with
+// conditional requests and keeps READMEs current.
+type CachePolicy struct {
+	MaxAge time.Duration
+	// StaleWhileRevalidate is how long after MaxAge a stale
+	// response may still be used while it is revalidated.
+	StaleWhileRevalidate time.Duration
+}
+
+// CachePolicies of the groups of endpoints serving activity.
+type CachePolicies struct {
+	// Cards are the SVG and raster activity cards, which are
+	// embedded in READMEs and proxied by GitHub's camo.
+	Cards CachePolicy
+	// Activity is the JSON of the current activity.
+	Activity CachePolicy
+}
+
+func (p CachePolicy) header() string {
+	if p.MaxAge <= 0 && p.StaleWhileRevalidate <= 0 {
+		return "no-cache"
+	}
+	header := fmt.Sprintf("public, max-age=%d", int(p.MaxAge.Seconds()))
+	if p.StaleWhileRevalidate > 0 {
+		header += fmt.Sprintf(", stale-while-revalidate=%d", int(p.StaleWhileRevalidate.Seconds()))
+	}
+	return header
+}
+
+// instanceID sets ETags of this process apart from those of
+// previous ones, as activity versions are counted from zero on
+// every start up.
+var instanceID = func() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}()
+
+// checkNotModified sets the cache headers of the response to a
+// resource of the version, where variant tells apart different
+// representations of it, such as themes. It reports whether the
+// request is conditional on a representation that is still
+// current, in which case the response should be a 304.
+func checkNotModified(c echo.Context, policy CachePolicy, version string, variant string, modifiedAt time.Time) bool {
+	etag := fmt.Sprintf(`"%s.%s"`, instanceID, version)
+	if variant != "" {
+		etag = fmt.Sprintf(`"%s.%s-%s"`, instanceID, version, variant)
+	}
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", policy.header())
+	header.Set("ETag", etag)
+	if !modifiedAt.IsZero() {
+		header.Set("Last-Modified", modifiedAt.UTC().Format(http.TimeFormat))
+	}
+
+	// If-Modified-Since is ignored when If-None-Match is sent
+	if inm := c.Request().Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	if ims := c.Request().Header.Get("If-Modified-Since"); ims != "" && !modifiedAt.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modifiedAt.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// etagMatches uses the weak comparison of If-None-Match.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified responds with a 304, keeping the validators set
+// by checkNotModified.
+func notModified(c echo.Context) error {
+	return c.NoContent(http.StatusNotModified)
+}
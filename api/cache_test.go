@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckNotModified(t *testing.T) {
+	modifiedAt := time.Date(2025, time.March, 12, 10, 17, 30, 500, time.UTC)
+	etag := func(version string) string {
+		return fmt.Sprintf(`"%s.%s"`, instanceID, version)
+	}
+	tests := []struct {
+		name        string
+		header      http.Header
+		notModified bool
+	}{
+		{"unconditional", http.Header{}, false},
+		{"matching etag", http.Header{"If-None-Match": {etag("7-dark")}}, true},
+		{"weak etag in list", http.Header{"If-None-Match": {etag("6-dark") + ", W/" + etag("7-dark")}}, true},
+		{"any etag", http.Header{"If-None-Match": {"*"}}, true},
+		{"stale etag", http.Header{"If-None-Match": {etag("6-dark")}}, false},
+		{"other variant", http.Header{"If-None-Match": {etag("7-light")}}, false},
+		// Versions restart from zero with the process
+		{"etag of previous process", http.Header{"If-None-Match": {`"7-dark"`}}, false},
+		{"not modified since", http.Header{"If-Modified-Since": {modifiedAt.Format(http.TimeFormat)}}, true},
+		{"modified since", http.Header{"If-Modified-Since": {modifiedAt.Add(-time.Minute).Format(http.TimeFormat)}}, false},
+		// If-Modified-Since is ignored along with If-None-Match
+		{"stale etag not modified since", http.Header{
+			"If-None-Match":     {etag("6-dark")},
+			"If-Modified-Since": {modifiedAt.Format(http.TimeFormat)},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/activity/svg", nil)
+			req.Header = tt.header
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			assert.Equal(t, tt.notModified, checkNotModified(c, CachePolicy{}, "7", "dark", modifiedAt))
+			assert.Equal(t, etag("7-dark"), rec.Header().Get("ETag"))
+			assert.Equal(t, "Wed, 12 Mar 2025 10:17:30 GMT", rec.Header().Get("Last-Modified"))
+			assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestCachePolicyHeader(t *testing.T) {
+	assert.Equal(t, "no-cache", CachePolicy{}.header())
+	assert.Equal(t, "public, max-age=60", CachePolicy{MaxAge: time.Minute}.header())
+	assert.Equal(t, "public, max-age=0, stale-while-revalidate=30", CachePolicy{StaleWhileRevalidate: 30 * time.Second}.header())
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/tifye/shigure/activity/code"
+	"github.com/tifye/shigure/assert"
+)
+
+func handleGetCodeGoals(logger *log.Logger, gt *code.GoalTracker) echo.HandlerFunc {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(gt)
+	return func(c echo.Context) error {
+		progress, err := gt.Progress(c.Request().Context())
+		if err != nil {
+			logger.Error("code goals progress", "err", err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.JSON(http.StatusOK, progress)
+	}
+}
+
+// handlePutCodeGoal creates a new goal when there is no id
+// path param and otherwise replaces the goal with that id.
+func handlePutCodeGoal(logger *log.Logger, gt *code.GoalTracker) echo.HandlerFunc {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(gt)
+	type request struct {
+		ID string `param:"id"`
+		code.Goal
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		req.Goal.ID = req.ID
+
+		goal, err := gt.PutGoal(req.Goal)
+		if err != nil {
+			switch {
+			case errors.Is(err, code.ErrInvalidGoal):
+				return c.String(http.StatusBadRequest, err.Error())
+			case errors.Is(err, code.ErrGoalNotFound):
+				return c.NoContent(http.StatusNotFound)
+			}
+			logger.Error("put code goal", "err", err, "goal", req.Goal)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.JSON(http.StatusOK, goal)
+	}
+}
+
+func handleDeleteCodeGoal(logger *log.Logger, gt *code.GoalTracker) echo.HandlerFunc {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(gt)
+	type request struct {
+		ID string `param:"id"`
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		err := gt.RemoveGoal(req.ID)
+		if err != nil {
+			if errors.Is(err, code.ErrGoalNotFound) {
+				return c.NoContent(http.StatusNotFound)
+			}
+			logger.Error("remove code goal", "err", err, "id", req.ID)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
@@ -12,21 +12,57 @@ func registerRoutes(e *echo.Echo, logger *log.Logger, config *viper.Viper, deps
 	e.GET("/", hello)
 	e.HEAD("/health", hello)
 
-	e.GET("/activity", handleGetYoutubeActivity(deps.YoutubeActivityClient))
-	e.GET("/activity/svg", handleGetYoutubeActivitySVG(logger, deps.YoutubeActivityClient))
-	e.GET("/youtube/activity/svg", handleGetYoutubeActivitySVG(logger, deps.YoutubeActivityClient)) // legacy
+	e.GET("/activity", handleGetYoutubeActivity(deps.YoutubeActivityClient, deps.CachePolicies.Activity))
+	e.GET("/activity/svg", handleGetYoutubeActivitySVG(logger, deps.YoutubeActivityClient, deps.CachePolicies.Cards))
+	e.GET("/youtube/activity/svg", handleGetYoutubeActivitySVG(logger, deps.YoutubeActivityClient, deps.CachePolicies.Cards)) // legacy
+	e.GET("/activity/png", handleGetYoutubeActivityImage(logger, deps.YoutubeActivityClient, deps.CachePolicies.Cards))
 	e.POST("/activity/clear", handlePostClearYoutubeActivity(deps.YoutubeActivityClient), requireAuthMiddleware(logger, config))
 	e.POST("/activity/youtube/:videoId", handlePostYoutubeActivity(logger, deps.YoutubeActivityClient), requireAuthMiddleware(logger, config))
+	e.POST("/activity/media/playback", handlePostMediaPlayback(logger, deps.YoutubeActivityClient), requireAuthMiddleware(logger, config))
+	e.POST("/activity/media/mpris", handlePostMPRISActivity(logger, deps.YoutubeActivityClient), requireAuthMiddleware(logger, config))
+	e.POST("/activity/media/:provider", handlePostMediaActivity(logger, deps.YoutubeActivityClient), requireAuthMiddleware(logger, config))
 
-	e.GET("/activity/vscode", handleGetVSCodeActivity(logger, deps.CodeActivityClient))
-	e.POST("/activity/vscode", handlePostVSCodeActivity(logger, deps.CodeActivityClient), requireAuthMiddleware(logger, config))
-	e.POST("/activity/vscode/redacted", handlePostMarkRepoRedacted(logger, deps.CodeActivityClient), requireAuthMiddleware(logger, config))
+	e.GET("/activity/vscode", handleGetVSCodeActivity(logger, deps.CodeActivityClient, deps.CachePolicies.Activity))
+	e.GET("/activity/vscode/svg", handleGetVSCodeActivitySVG(logger, deps.CodeActivityClient, deps.CachePolicies.Cards))
+	e.GET("/activity/vscode/png", handleGetVSCodeActivityImage(logger, deps.CodeActivityClient, deps.CachePolicies.Cards))
+	e.GET("/activity/vscode/history", handleGetVSCodeActivityHistory(logger, deps.CodeActivityClient))
+	e.POST("/activity/vscode", handlePostVSCodeActivity(logger, deps.CodeActivityClient), requireUserAuthMiddleware(logger, config))
+	e.POST("/activity/vscode/redacted", handlePostMarkRepoRedacted(logger, deps.CodeActivityClient), requireAuthMiddleware(logger, config)) // legacy
+	e.GET("/activity/vscode/redaction/rules", handleGetRedactionRules(logger, deps.CodeActivityClient), requireUserAuthMiddleware(logger, config))
+	e.POST("/activity/vscode/redaction/rules", handlePutRedactionRule(logger, deps.CodeActivityClient), requireUserAuthMiddleware(logger, config))
+	e.PUT("/activity/vscode/redaction/rules/:id", handlePutRedactionRule(logger, deps.CodeActivityClient), requireUserAuthMiddleware(logger, config))
+	e.DELETE("/activity/vscode/redaction/rules/:id", handleDeleteRedactionRule(logger, deps.CodeActivityClient), requireUserAuthMiddleware(logger, config))
+	e.GET("/activity/vscode/repositories/aliases", handleGetRepositoryAliases(logger, deps.CodeActivityClient), requireAuthMiddleware(logger, config))
+	e.PUT("/activity/vscode/repositories/aliases", handlePutRepositoryAlias(logger, deps.CodeActivityClient), requireAuthMiddleware(logger, config))
+	e.DELETE("/activity/vscode/repositories/aliases", handleDeleteRepositoryAlias(logger, deps.CodeActivityClient), requireAuthMiddleware(logger, config))
+
+	e.GET("/v1/activity/editor", handleGetEditorActivity(deps.CodeActivityClient, deps.CachePolicies.Activity))
+	e.POST("/v1/activity/editor", handlePostEditorActivity(logger, deps.CodeActivityClient), requireUserAuthMiddleware(logger, config))
+
+	e.POST("/api/v1/users/current/heartbeats", handlePostWakaTimeHeartbeat(logger, deps.CodeActivityClient), requireWakaTimeAuthMiddleware(logger, config))
+	e.POST("/api/v1/users/current/heartbeats.bulk", handlePostWakaTimeHeartbeatsBulk(logger, deps.CodeActivityClient), requireWakaTimeAuthMiddleware(logger, config))
 
 	e.GET("/auth/token", handleGetToken(logger, config))
 	e.GET("/auth/token/generate", handleGetGenerateToken(logger, config), requireAuthMiddleware(logger, config))
 	e.POST("/auth/token/verify", handlePostVerifyToken(logger, config))
 
 	e.GET("/stats/code", handleGetCodeStats(logger, deps.CodeActivityClient))
+	e.GET("/stats/code/calendar", handleGetCodeCalendar(logger, deps.CodeActivityClient))
+	e.GET("/stats/code/calendar/svg", handleGetCodeCalendarSVG(logger, deps.CodeActivityClient))
+	e.GET("/stats/code/goals", handleGetCodeGoals(logger, deps.CodeGoalTracker))
+	e.POST("/stats/code/goals", handlePutCodeGoal(logger, deps.CodeGoalTracker), requireAuthMiddleware(logger, config))
+	e.PUT("/stats/code/goals/:id", handlePutCodeGoal(logger, deps.CodeGoalTracker), requireAuthMiddleware(logger, config))
+	e.DELETE("/stats/code/goals/:id", handleDeleteCodeGoal(logger, deps.CodeGoalTracker), requireAuthMiddleware(logger, config))
+	e.GET("/stats/code/leaderboard", handleGetCodeLeaderboard(logger, deps.CodeActivityClient))
+	e.GET("/stats/code/:user", handleGetCodeStats(logger, deps.CodeActivityClient))
+	e.GET("/stats/code/:user/calendar", handleGetCodeCalendar(logger, deps.CodeActivityClient))
+	e.GET("/stats/code/:user/calendar/svg", handleGetCodeCalendarSVG(logger, deps.CodeActivityClient))
+	e.GET("/stats/youtube", handleGetYoutubeStats(logger, deps.YoutubeActivityClient, deps.StatsLocation))
+
+	e.GET("/scheduler/jobs", handleGetSchedulerJobs(logger, deps.Scheduler), requireAuthMiddleware(logger, config))
+	e.POST("/scheduler/jobs/:name/trigger", handlePostTriggerSchedulerJob(logger, deps.Scheduler), requireAuthMiddleware(logger, config))
+
+	e.GET("/admin/youtube/quota", handleGetYoutubeQuota(logger, deps.YoutubeProvider), requireAuthMiddleware(logger, config))
 
 	e.GET("/ws", handleWebsocketConn(logger, deps.WebSocketMux, deps.NewSessionCookie))
 }
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/tifye/shigure/assert"
+	"github.com/tifye/shigure/scheduler"
+)
+
+func handleGetSchedulerJobs(logger *log.Logger, sched *scheduler.Scheduler) echo.HandlerFunc {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(sched)
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, sched.Jobs())
+	}
+}
+
+// handlePostTriggerSchedulerJob runs a job right away and
+// responds once it has finished.
+func handlePostTriggerSchedulerJob(logger *log.Logger, sched *scheduler.Scheduler) echo.HandlerFunc {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(sched)
+	type request struct {
+		Name string `param:"name"`
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		err := sched.Trigger(c.Request().Context(), req.Name)
+		if err != nil {
+			if errors.Is(err, scheduler.ErrJobNotFound) {
+				return c.NoContent(http.StatusNotFound)
+			}
+			logger.Error("trigger job", "err", err, "name", req.Name)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/tifye/shigure/activity/code"
 	"github.com/tifye/shigure/activity/youtube"
 	"github.com/tifye/shigure/mux"
+	"github.com/tifye/shigure/scheduler"
 	"golang.org/x/time/rate"
 )
 
@@ -27,8 +28,13 @@ var (
 
 type ServerDependencies struct {
 	YoutubeActivityClient *youtube.ActivityClient
+	YoutubeProvider       *youtube.YoutubeProvider
 	CodeActivityClient    *code.ActivityClient
 	CodeActivityStore     *code.CodeActivityStore
+	CodeGoalTracker       *code.GoalTracker
+	StatsLocation         *time.Location
+	CachePolicies         CachePolicies
+	Scheduler             *scheduler.Scheduler
 	WebSocketMux          *mux.Mux
 	SessionStore          sessions.Store
 	NewSessionCookie      func(s *sessions.Session) (*http.Cookie, error)
@@ -66,7 +72,7 @@ func NewServer(logger *log.Logger, config *viper.Viper, deps *ServerDependencies
 	e.Use(middleware.RateLimiterWithConfig(rlimiterConfig))
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"*"},
-		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodOptions},
+		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
 		AllowHeaders: []string{
 			echo.HeaderOrigin,
 			echo.HeaderContentType,
@@ -1,19 +1,30 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/labstack/echo/v4"
 	"github.com/tifye/shigure/activity/code"
+	"github.com/tifye/shigure/activity/youtube"
 	"github.com/tifye/shigure/assert"
 )
 
+const dateLayout = "2006-01-02"
+
 func handleGetCodeStats(logger *log.Logger, client *code.ActivityClient) echo.HandlerFunc {
 	assert.AssertNotNil(logger)
 	assert.AssertNotNil(client)
 	return func(c echo.Context) error {
-		stats, err := client.CodeStats(c.Request().Context())
+		filter, err := bindStatsFilter(c, client)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		stats, err := client.CodeStats(c.Request().Context(), filter)
 		if err != nil {
 			logger.Error("code stats", "err", err)
 			return c.NoContent(http.StatusInternalServerError)
@@ -22,3 +33,215 @@ func handleGetCodeStats(logger *log.Logger, client *code.ActivityClient) echo.Ha
 		return c.JSON(http.StatusOK, stats)
 	}
 }
+
+// handleGetYoutubeStats responds with the watch history stats,
+// the query params range, from and to apply as for code stats.
+func handleGetYoutubeStats(logger *log.Logger, client *youtube.ActivityClient, loc *time.Location) echo.HandlerFunc {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(client)
+	assert.AssertNotNil(loc)
+	return func(c echo.Context) error {
+		from, to, err := bindStatsRange(c, loc)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		stats, err := client.Stats(c.Request().Context(), youtube.StatsFilter{From: from, To: to})
+		if err != nil {
+			logger.Error("youtube stats", "err", err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.JSON(http.StatusOK, stats)
+	}
+}
+
+// bindStatsFilter reads a code.StatsFilter from the user path
+// param, defaulting to the owner, and the query params range,
+// from, to, repo, language, workspace and editor.
+//
+// from and to take precedence over the bounds of range and
+// accept either RFC 3339 timestamps or dates. Dates are
+// interpreted in the client's location and to is inclusive
+// of the whole day.
+func bindStatsFilter(c echo.Context, client *code.ActivityClient) (code.StatsFilter, error) {
+	var req struct {
+		Repo      string `query:"repo"`
+		Language  string `query:"language"`
+		Workspace string `query:"workspace"`
+		Editor    string `query:"editor"`
+	}
+	err := (&echo.DefaultBinder{}).BindQueryParams(c, &req)
+	if err != nil {
+		return code.StatsFilter{}, err
+	}
+
+	user := c.Param("user")
+	if user == "" {
+		user = code.OwnerUser
+	}
+	if !code.ValidUser(user) {
+		return code.StatsFilter{}, code.ErrInvalidUser
+	}
+
+	filter := code.StatsFilter{
+		User:       user,
+		Repository: req.Repo,
+		Language:   req.Language,
+		Workspace:  req.Workspace,
+		Editor:     req.Editor,
+	}
+	if req.Language != "" {
+		filter.Language = code.NormalizeLanguage(req.Language, "")
+	}
+	if req.Repo != "" {
+		filter.Repository = client.CanonicalRepository(req.Repo)
+	}
+
+	filter.From, filter.To, err = bindStatsRange(c, client.Location())
+	if err != nil {
+		return code.StatsFilter{}, err
+	}
+
+	return filter, nil
+}
+
+// bindStatsRange reads a time range from the query params range,
+// from and to, see bindStatsFilter. Zero bounds are unbounded.
+func bindStatsRange(c echo.Context, loc *time.Location) (from, to time.Time, err error) {
+	var req struct {
+		Range string `query:"range"`
+		From  string `query:"from"`
+		To    string `query:"to"`
+	}
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if req.Range != "" {
+		from, to, err = code.RangePreset(req.Range, time.Now().In(loc))
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	if req.From != "" {
+		from, _, err = parseStatsTime(req.From, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %s", err)
+		}
+	}
+
+	if req.To != "" {
+		var isDate bool
+		to, isDate, err = parseStatsTime(req.To, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %s", err)
+		}
+		if isDate {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+
+	return from, to, nil
+}
+
+// handleGetCodeLeaderboard ranks all users by time spent, the
+// same query params as for the stats of a single user apply.
+func handleGetCodeLeaderboard(logger *log.Logger, client *code.ActivityClient) echo.HandlerFunc {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(client)
+	return func(c echo.Context) error {
+		filter, err := bindStatsFilter(c, client)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		if c.QueryParam("range") == "" && c.QueryParam("from") == "" {
+			filter.From, filter.To, _ = client.RangePreset(code.RangeLast7Days)
+		}
+
+		leaderboard, err := client.Leaderboard(c.Request().Context(), filter)
+		if err != nil {
+			logger.Error("code leaderboard", "err", err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		return c.JSON(http.StatusOK, leaderboard)
+	}
+}
+
+func parseStatsTime(s string, loc *time.Location) (t time.Time, isDate bool, err error) {
+	t, err = time.ParseInLocation(dateLayout, s, loc)
+	if err == nil {
+		return t, true, nil
+	}
+
+	t, err = time.Parse(time.RFC3339, s)
+	return t, false, err
+}
+
+func handleGetCodeCalendar(logger *log.Logger, client *code.ActivityClient) echo.HandlerFunc {
+	return handleCodeCalendar(logger, client, func(c echo.Context, stats code.CalendarStats) error {
+		return c.JSON(http.StatusOK, stats)
+	})
+}
+
+func handleGetCodeCalendarSVG(logger *log.Logger, client *code.ActivityClient) echo.HandlerFunc {
+	return handleCodeCalendar(logger, client, func(c echo.Context, stats code.CalendarStats) error {
+		c.Response().Header().Set(echo.HeaderContentType, "image/svg+xml")
+		c.Response().Header().Add("Cache-Control", "no-cache")
+		c.Response().WriteHeader(http.StatusOK)
+		if err := code.StreamCalendarSVG(c.Response(), stats); err != nil {
+			logger.Errorf("Get calendar SVG: %s", err)
+		}
+		return nil
+	})
+}
+
+// handleCodeCalendar responds to requests for calendar stats
+// with respond, once the stats have been read.
+func handleCodeCalendar(logger *log.Logger, client *code.ActivityClient, respond func(c echo.Context, stats code.CalendarStats) error) echo.HandlerFunc {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(client)
+	return func(c echo.Context) error {
+		stats, err := codeCalendarStats(c, client)
+		if err != nil {
+			var httpErr *echo.HTTPError
+			if errors.As(err, &httpErr) {
+				return err
+			}
+			logger.Error("code calendar stats", "err", err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return respond(c, stats)
+	}
+}
+
+// codeCalendarStats reads the year query param, defaulting to the
+// current year, alongside the repo, language, workspace and
+// editor filters.
+func codeCalendarStats(c echo.Context, client *code.ActivityClient) (code.CalendarStats, error) {
+	var req struct {
+		Year int `query:"year"`
+	}
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return code.CalendarStats{}, echo.NewHTTPError(http.StatusBadRequest, "invalid year")
+	}
+	if req.Year == 0 {
+		req.Year = time.Now().In(client.Location()).Year()
+	}
+	if req.Year < 2000 || req.Year > 9999 {
+		return code.CalendarStats{}, echo.NewHTTPError(http.StatusBadRequest, "invalid year")
+	}
+
+	filter, err := bindStatsFilter(c, client)
+	if err != nil {
+		return code.CalendarStats{}, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return client.CalendarStats(c.Request().Context(), req.Year, filter)
+}
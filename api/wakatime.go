@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"github.com/tifye/shigure/activity/code"
+	"github.com/tifye/shigure/assert"
+)
+
+const maxWakaTimeBulkHeartbeats = 100
+
+type wakaTimeHeartbeatResponse struct {
+	Data code.WakaTimeHeartbeat `json:"data"`
+}
+
+func handlePostWakaTimeHeartbeat(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+	assert.AssertNotNil(ac)
+	return func(c echo.Context) error {
+		var heartbeat code.WakaTimeHeartbeat
+		if err := c.Bind(&heartbeat); err != nil {
+			return err
+		}
+
+		if err := setWakaTimeActivity(c, ac, heartbeat); err != nil {
+			logger.Error("set wakatime activity", "err", err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusCreated, wakaTimeHeartbeatResponse{Data: heartbeat})
+	}
+}
+
+// handlePostWakaTimeHeartbeatsBulk responds in the format expected
+// by the WakaTime CLI, a status per heartbeat.
+func handlePostWakaTimeHeartbeatsBulk(logger *log.Logger, ac *code.ActivityClient) echo.HandlerFunc {
+	assert.AssertNotNil(ac)
+	return func(c echo.Context) error {
+		var heartbeats []code.WakaTimeHeartbeat
+		if err := c.Bind(&heartbeats); err != nil {
+			return err
+		}
+
+		if len(heartbeats) > maxWakaTimeBulkHeartbeats {
+			return c.String(http.StatusBadRequest, "too many heartbeats")
+		}
+
+		responses := make([][2]any, len(heartbeats))
+		for i, heartbeat := range heartbeats {
+			status := http.StatusCreated
+			if err := setWakaTimeActivity(c, ac, heartbeat); err != nil {
+				logger.Error("set wakatime activity", "err", err)
+				status = http.StatusInternalServerError
+			}
+			responses[i] = [2]any{wakaTimeHeartbeatResponse{Data: heartbeat}, status}
+		}
+
+		return c.JSON(http.StatusCreated, map[string]any{
+			"responses": responses,
+		})
+	}
+}
+
+// setWakaTimeActivity records file heartbeats of the authenticated
+// user, others such as visited domains are accepted but ignored.
+func setWakaTimeActivity(c echo.Context, ac *code.ActivityClient, heartbeat code.WakaTimeHeartbeat) error {
+	if !heartbeat.IsFile() || heartbeat.Time <= 0 {
+		return nil
+	}
+
+	editor := code.EditorFromWakaTimeUserAgent(c.Request().UserAgent())
+	return ac.SetActivity(c.Request().Context(), authenticatedUser(c), heartbeat.EditorActivity(editor))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tifye/shigure/activity/code"
+	"github.com/tifye/shigure/storage"
+)
+
+func newImportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import activity from other trackers",
+	}
+
+	cmd.AddCommand(newImportWakaTimeCommand())
+
+	return cmd
+}
+
+func newImportWakaTimeCommand() *cobra.Command {
+	var (
+		dbPath      string
+		rulesPath   string
+		aliasesPath string
+		user        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "wakatime <export.json>",
+		Short: "Import a WakaTime data export into the code activity database",
+		Long: `Import the heartbeats of a WakaTime data export into the code activity
+database. Redaction rules and repository aliases are applied the same as
+for live activity and heartbeats which have already been imported are
+skipped.
+
+DuckDB only allows a single process to open the database, so shigure
+has to be stopped while importing.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			activities, err := code.ParseWakaTimeExport(file)
+			if err != nil {
+				return fmt.Errorf("parse export: %s", err)
+			}
+
+			db, err := storage.OpenDuckDB(dbPath)
+			if err != nil {
+				return fmt.Errorf("open database: %s", err)
+			}
+			defer db.Close()
+
+			if rulesPath == "" {
+				rulesPath = code.RedactionRulesPath(user)
+			}
+			importer, err := code.NewImporter(code.NewCodeActivityStore(db, code.DefaultIdleTimeout), user, rulesPath, aliasesPath)
+			if err != nil {
+				return fmt.Errorf("new importer: %s", err)
+			}
+
+			inserted, err := importer.Import(cmd.Context(), activities)
+			if err != nil {
+				return fmt.Errorf("import: %s", err)
+			}
+
+			cmd.Printf("imported %d of %d heartbeats\n", inserted, len(activities))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dbPath, "db", storage.DefaultDuckDBPath, "path to the DuckDB database")
+	cmd.Flags().StringVar(&rulesPath, "redaction-rules", "", "path to the redaction rules, defaults to those of the user")
+	cmd.Flags().StringVar(&aliasesPath, "repository-aliases", code.DefaultRepositoryAliasesPath, "path to the repository aliases")
+	cmd.Flags().StringVar(&user, "user", code.OwnerUser, "user to import the heartbeats as")
+
+	return cmd
+}
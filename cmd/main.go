@@ -24,6 +24,8 @@ func newRootCommand() *cobra.Command {
 		},
 	}
 
+	cmd.AddCommand(newImportCommand())
+
 	return cmd
 }
 
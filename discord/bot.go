@@ -120,6 +120,15 @@ func (b *ChatBot) Stop() error {
 	return b.sesh.Close()
 }
 
+// SendEmbed posts embed in the channel with channelID.
+func (b *ChatBot) SendEmbed(ctx context.Context, channelID string, embed *discordgo.MessageEmbed) error {
+	assert.AssertNotEmpty(channelID)
+	assert.AssertNotNil(embed)
+
+	_, err := b.sesh.ChannelMessageSendEmbed(channelID, embed, discordgo.WithContext(ctx))
+	return err
+}
+
 type chatMessage struct {
 	Actor   string `json:"actor"`
 	Message string `json:"message"`
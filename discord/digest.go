@@ -0,0 +1,66 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tifye/shigure/activity/code"
+)
+
+const digestEmbedColor = 0x7aa2f7
+
+// CodeDigestEmbed formats a coding digest as an embed.
+func CodeDigestEmbed(d code.Digest) *discordgo.MessageEmbed {
+	title := "Daily coding digest"
+	period := d.From.Format("Monday, January 2")
+	if d.Period == code.DigestWeekly {
+		title = "Weekly coding digest"
+		period = fmt.Sprintf("%s – %s", d.From.Format("January 2"), d.To.AddDate(0, 0, -1).Format("January 2"))
+	}
+
+	comparison := "No activity the period before"
+	if change, ok := d.Change(); ok {
+		comparison = fmt.Sprintf("%+.0f%% compared to %s", change, d.PreviousTimeSpent)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: period,
+		Color:       digestEmbedColor,
+		Timestamp:   d.To.Format(time.RFC3339),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Total time", Value: fmt.Sprintf("**%s**\n%s", d.TimeSpent, comparison)},
+		},
+	}
+
+	if len(d.TopRepositories) > 0 {
+		var sb strings.Builder
+		for i, r := range d.TopRepositories {
+			repo := r.Repository
+			if repo == "" {
+				repo = "Unknown"
+			}
+			fmt.Fprintf(&sb, "%d. %s · %s\n", i+1, repo, r.TimeSpent)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Top repositories", Value: sb.String(), Inline: true})
+	}
+
+	if len(d.TopLanguages) > 0 {
+		var sb strings.Builder
+		for i, l := range d.TopLanguages {
+			fmt.Fprintf(&sb, "%d. %s · %s\n", i+1, l.Language, l.TimeSpent)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Top languages", Value: sb.String(), Inline: true})
+	}
+
+	if s := d.LongestSession; s != nil {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Longest session",
+			Value: fmt.Sprintf("%s, %s – %s", s.Duration, s.Start.In(d.From.Location()).Format("15:04"), s.End.In(d.From.Location()).Format("15:04")),
+		})
+	}
+
+	return embed
+}
@@ -0,0 +1,42 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ExecuteWebhook posts a message with content to the
+// webhook at webhookURL.
+func ExecuteWebhook(ctx context.Context, webhookURL string, content string) error {
+	return ExecuteWebhookParams(ctx, webhookURL, &discordgo.WebhookParams{Content: content})
+}
+
+// ExecuteWebhookParams posts a message, such as one with
+// embeds, to the webhook at webhookURL.
+func ExecuteWebhookParams(ctx context.Context, webhookURL string, params *discordgo.WebhookParams) error {
+	bodyBytes, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("marhsall body: %s", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook res: %s", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook res: unexpected status %s", res.Status)
+	}
+	return nil
+}
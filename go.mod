@@ -3,6 +3,7 @@ module github.com/tifye/shigure
 go 1.25.0
 
 require (
+	github.com/HugoSmits86/nativewebp v0.9.3
 	github.com/bwmarrin/discordgo v0.29.0
 	github.com/charmbracelet/log v0.4.2
 	github.com/golang-jwt/jwt/v5 v5.2.1
@@ -16,6 +17,7 @@ require (
 	github.com/pquerna/otp v1.4.0
 	github.com/spf13/viper v1.19.0
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/image v0.28.0
 	golang.org/x/time v0.11.0
 )
 
@@ -10,6 +10,7 @@ import (
 	"os/signal"
 	"time"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/charmbracelet/log"
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
@@ -22,6 +23,7 @@ import (
 	"github.com/tifye/shigure/discord"
 	"github.com/tifye/shigure/mux"
 	"github.com/tifye/shigure/personalsite"
+	"github.com/tifye/shigure/scheduler"
 	"github.com/tifye/shigure/sshapp"
 	"github.com/tifye/shigure/storage"
 )
@@ -116,6 +118,7 @@ func initDependencies(logger *log.Logger, config *viper.Viper) (deps *api.Server
 
 	youtubeApiKey := config.GetString("YOUTUBE_DATA_API_KEY")
 	assert.AssertNotEmpty(youtubeApiKey)
+	config.SetDefault("OEMBED_ENDPOINT", "https://noembed.com/embed")
 
 	mux2 := mux.NewMux(logger.WithPrefix("mux"))
 
@@ -133,9 +136,89 @@ func initDependencies(logger *log.Logger, config *viper.Viper) (deps *api.Server
 		return nil, cfs, err
 	}
 	cfs.Defer(db.Close)
-	codeActivityStore := code.NewCodeActivityStore(db)
-	codeActivityClient := code.NewActivityClient(logger.WithPrefix("code"), mux2, codeActivityStore)
+	config.SetDefault("STATS_TIMEZONE", "UTC")
+	statsLocation, err := time.LoadLocation(config.GetString("STATS_TIMEZONE"))
+	if err != nil {
+		return nil, cfs, fmt.Errorf("load stats timezone: %s", err)
+	}
+	config.SetDefault("CODE_IDLE_TIMEOUT", code.DefaultIdleTimeout)
+	idleTimeout := config.GetDuration("CODE_IDLE_TIMEOUT")
+	if idleTimeout <= 0 {
+		return nil, cfs, fmt.Errorf("invalid code idle timeout %q", config.GetString("CODE_IDLE_TIMEOUT"))
+	}
+	codeActivityStore := code.NewCodeActivityStore(db, idleTimeout)
+	normalized, err := codeActivityStore.NormalizeLanguages(context.Background())
+	if err != nil {
+		return nil, cfs, fmt.Errorf("normalize code activity languages: %s", err)
+	}
+	if normalized > 0 {
+		logger.Info("normalized code activity languages", "reports", normalized)
+	}
+	config.SetDefault("CODE_PRESENCE_IDLE", code.DefaultPresenceThresholds.Idle)
+	config.SetDefault("CODE_PRESENCE_AWAY", code.DefaultPresenceThresholds.Away)
+	config.SetDefault("CODE_PRESENCE_OFFLINE", code.DefaultPresenceThresholds.Offline)
+	presence := code.PresenceThresholds{
+		Idle:    config.GetDuration("CODE_PRESENCE_IDLE"),
+		Away:    config.GetDuration("CODE_PRESENCE_AWAY"),
+		Offline: config.GetDuration("CODE_PRESENCE_OFFLINE"),
+	}
+	if err := presence.Validate(); err != nil {
+		return nil, cfs, err
+	}
+	var placeholder *code.EditorActivity
+	if path := config.GetString("CODE_PLACEHOLDER_ACTIVITY_PATH"); path != "" {
+		a, err := code.LoadPlaceholderActivity(path)
+		if err != nil {
+			return nil, cfs, fmt.Errorf("load placeholder activity: %s", err)
+		}
+		placeholder = &a
+	}
+	codeActivityClient := code.NewActivityClient(logger.WithPrefix("code"), mux2, codeActivityStore, code.ActivityClientOptions{
+		Location:              statsLocation,
+		AutoRedactSecretFiles: config.GetBool("CODE_AUTO_REDACT_SECRET_FILES"),
+		Presence:              presence,
+		Placeholder:           placeholder,
+	})
+	codeCtx, cancelCode := context.WithCancel(context.Background())
+	go codeActivityClient.Run(codeCtx)
+	cfs.Defer(func() error {
+		cancelCode()
+		return nil
+	})
+	normalized, err = codeActivityStore.NormalizeRepositories(context.Background(), codeActivityClient.CanonicalRepository)
+	if err != nil {
+		return nil, cfs, fmt.Errorf("normalize code activity repositories: %s", err)
+	}
+	if normalized > 0 {
+		logger.Info("normalized code activity repositories", "reports", normalized)
+	}
 	mux2.RegisterHandler(codeActivityClient.MessageType(), codeActivityClient)
+	mux2.RegisterHandler(codeActivityClient.LegacyMessageType(), codeActivityClient)
+
+	webhookURL := config.GetString("DISCORD_WEBHOOK_URL")
+	// Without a webhook goals are tracked without being notified
+	var notifyGoal code.GoalNotifier
+	if webhookURL != "" {
+		notifyGoal = func(ctx context.Context, message string) error {
+			return discord.ExecuteWebhook(ctx, webhookURL, message)
+		}
+	}
+	codeGoalTracker, err := code.NewGoalTracker(logger.WithPrefix("code-goals"), mux2, codeActivityStore, code.GoalTrackerOptions{
+		Location:            statsLocation,
+		Notify:              notifyGoal,
+		CanonicalRepository: codeActivityClient.CanonicalRepository,
+	})
+	if err != nil {
+		return nil, cfs, fmt.Errorf("new code goal tracker: %s", err)
+	}
+	mux2.RegisterHandler(codeGoalTracker.MessageType(), codeGoalTracker)
+	mux2.AddSubscriptionHook(codeGoalTracker.MessageType(), codeGoalTracker.HandleSubscription)
+	goalsCtx, cancelGoals := context.WithCancel(context.Background())
+	go codeGoalTracker.Run(goalsCtx)
+	cfs.Defer(func() error {
+		cancelGoals()
+		return nil
+	})
 
 	discordBot, err := discord.NewChatBot(
 		logger.WithPrefix("chatbot"),
@@ -160,6 +243,84 @@ func initDependencies(logger *log.Logger, config *viper.Viper) (deps *api.Server
 	mux2.RegisterHandler(discordBot.MessageType(), discordBot)
 	mux2.AddSubscriptionHook(discordBot.MessageType(), discordBot.HandleMuxChatSubscription)
 
+	config.SetDefault("CODE_DIGEST_DAILY_CRON", "0 9 * * *")
+	config.SetDefault("CODE_DIGEST_WEEKLY_CRON", "0 9 * * 1")
+	config.SetDefault("CODE_DIGEST_WEBHOOK_URL", webhookURL)
+	sendDigest := func(ctx context.Context, embed *discordgo.MessageEmbed) error {
+		if channelID := config.GetString("CODE_DIGEST_DISCORD_CHANNEL_ID"); channelID != "" {
+			return discordBot.SendEmbed(ctx, channelID, embed)
+		}
+		return discord.ExecuteWebhookParams(ctx, config.GetString("CODE_DIGEST_WEBHOOK_URL"), &discordgo.WebhookParams{
+			Embeds: []*discordgo.MessageEmbed{embed},
+		})
+	}
+	sched := scheduler.New(logger.WithPrefix("scheduler"), statsLocation)
+	digests := map[code.DigestPeriod]string{
+		code.DigestDaily:  config.GetString("CODE_DIGEST_DAILY_CRON"),
+		code.DigestWeekly: config.GetString("CODE_DIGEST_WEEKLY_CRON"),
+	}
+	for period, spec := range digests {
+		// Empty env vars fall back to the defaults, "off" disables a digest
+		if spec == "" || spec == "off" {
+			continue
+		}
+		err := sched.Add("code-digest-"+string(period), spec, func(ctx context.Context) error {
+			digest, err := codeActivityClient.Digest(ctx, period, time.Now())
+			if err != nil {
+				return fmt.Errorf("code digest: %s", err)
+			}
+			return sendDigest(ctx, discord.CodeDigestEmbed(digest))
+		})
+		if err != nil {
+			return nil, cfs, fmt.Errorf("schedule %s code digest: %s", period, err)
+		}
+	}
+	schedCtx, cancelSched := context.WithCancel(context.Background())
+	go sched.Run(schedCtx)
+	cfs.Defer(func() error {
+		cancelSched()
+		return nil
+	})
+
+	oembedProvider := youtube.NewOEmbedProvider(config.GetString("OEMBED_ENDPOINT"))
+	config.SetDefault("YOUTUBE_CACHE_DIR", youtube.DefaultYoutubeCacheDir)
+	config.SetDefault("YOUTUBE_VIDEO_CACHE_SIZE", youtube.DefaultVideoCacheSize)
+	config.SetDefault("YOUTUBE_QUOTA_DAILY_LIMIT", youtube.DefaultYoutubeQuotaLimit)
+	youtubeProvider, err := youtube.NewYoutubeProvider(youtubeApiKey, youtube.YoutubeProviderOptions{
+		CacheDir:   config.GetString("YOUTUBE_CACHE_DIR"),
+		CacheSize:  config.GetInt("YOUTUBE_VIDEO_CACHE_SIZE"),
+		QuotaLimit: config.GetInt("YOUTUBE_QUOTA_DAILY_LIMIT"),
+		Fallback:   oembedProvider,
+	})
+	if err != nil {
+		return nil, cfs, fmt.Errorf("youtube provider: %s", err)
+	}
+	mediaProviders := []youtube.MediaProvider{youtubeProvider, oembedProvider}
+	twitchClientID, twitchAccessToken := config.GetString("TWITCH_CLIENT_ID"), config.GetString("TWITCH_ACCESS_TOKEN")
+	if twitchClientID != "" && twitchAccessToken != "" {
+		mediaProviders = append(mediaProviders, youtube.NewTwitchProvider(twitchClientID, twitchAccessToken))
+	} else {
+		logger.Info("twitch media provider disabled, missing TWITCH_CLIENT_ID or TWITCH_ACCESS_TOKEN")
+	}
+	config.SetDefault("YOUTUBE_THUMBNAIL_CACHE_DIR", youtube.DefaultThumbnailCacheDir)
+	config.SetDefault("YOUTUBE_THUMBNAIL_CACHE_MAX_BYTES", youtube.DefaultThumbnailCacheMaxBytes)
+	config.SetDefault("YOUTUBE_THUMBNAIL_CACHE_TTL", youtube.DefaultThumbnailCacheTTL)
+	thumbnails, err := youtube.NewThumbnailCache(
+		config.GetString("YOUTUBE_THUMBNAIL_CACHE_DIR"),
+		config.GetInt64("YOUTUBE_THUMBNAIL_CACHE_MAX_BYTES"),
+		config.GetDuration("YOUTUBE_THUMBNAIL_CACHE_TTL"),
+	)
+	if err != nil {
+		return nil, cfs, fmt.Errorf("thumbnail cache: %s", err)
+	}
+	youtubeActivityClient := youtube.NewClient(logger.WithPrefix("youtube"), youtube.NewYoutubeActivityStore(db), mux2, youtube.ActivityClientOptions{
+		Providers:      mediaProviders,
+		SVGTemplateDir: config.GetString("YOUTUBE_SVG_TEMPLATE_DIR"),
+		Thumbnails:     thumbnails,
+	})
+	mux2.RegisterHandler(youtubeActivityClient.MessageType(), youtubeActivityClient)
+	mux2.AddSubscriptionHook(youtubeActivityClient.MessageType(), youtubeActivityClient.HandleSubscription)
+
 	sessionStore := sessions.NewFilesystemStore("", []byte(config.GetString("OTP_SECRET")))
 	sessionStore.Options.Partitioned = true
 	sessionStore.Options.Secure = true
@@ -171,10 +332,27 @@ func initDependencies(logger *log.Logger, config *viper.Viper) (deps *api.Server
 		return sessions.NewCookie(s.Name(), val, s.Options), nil
 	}
 
+	// Durations default to 0, responses are revalidated every time
+	cachePolicies := api.CachePolicies{
+		Cards: api.CachePolicy{
+			MaxAge:               config.GetDuration("CACHE_CARDS_MAX_AGE"),
+			StaleWhileRevalidate: config.GetDuration("CACHE_CARDS_STALE_WHILE_REVALIDATE"),
+		},
+		Activity: api.CachePolicy{
+			MaxAge:               config.GetDuration("CACHE_ACTIVITY_MAX_AGE"),
+			StaleWhileRevalidate: config.GetDuration("CACHE_ACTIVITY_STALE_WHILE_REVALIDATE"),
+		},
+	}
+
 	return &api.ServerDependencies{
-		YoutubeActivityClient: youtube.NewClient(logger.WithPrefix("youtube"), youtubeApiKey),
+		YoutubeActivityClient: youtubeActivityClient,
+		YoutubeProvider:       youtubeProvider,
 		CodeActivityClient:    codeActivityClient,
 		CodeActivityStore:     codeActivityStore,
+		CodeGoalTracker:       codeGoalTracker,
+		Scheduler:             sched,
+		StatsLocation:         statsLocation,
+		CachePolicies:         cachePolicies,
 		WebSocketMux:          mux2,
 		SessionStore:          sessionStore,
 		NewSessionCookie:      newSessionCookie,
@@ -1,16 +1,15 @@
 package personalsite
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/tifye/shigure/assert"
+	"github.com/tifye/shigure/discord"
 	"github.com/tifye/shigure/mux"
 )
 
@@ -84,7 +83,9 @@ func (r *RoomHub) HandleMessage(c *mux.Channel, msg []byte) error {
 		go func() {
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
-			notifyDiscord(ctx, r.webhookURL, r.NotifyContent)
+			if err := discord.ExecuteWebhook(ctx, r.webhookURL, r.NotifyContent); err != nil {
+				r.logger.Error("notify discord", "err", err)
+			}
 		}()
 	}
 
@@ -131,27 +132,3 @@ type userUnregistered struct {
 	ID    []byte `json:"id"`
 	Unreg bool   `json:"delete,omitzero"`
 }
-
-type webhookBody struct {
-	Content string `json:"content"`
-}
-
-func notifyDiscord(ctx context.Context, webhookURL string, content string) error {
-	body := webhookBody{Content: content}
-	bodyBytes, err := json.Marshal(body)
-	if err != nil {
-		return fmt.Errorf("marhsall body: %s", err)
-	}
-	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewReader(bodyBytes))
-	if err != nil {
-		return err
-	}
-	req.Header.Add("Content-Type", "application/json")
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("webhook res: %s", err)
-	}
-	res.Body.Close()
-	return nil
-}
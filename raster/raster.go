@@ -0,0 +1,195 @@
+// Package raster draws activity cards as images for the places
+// that can't show SVG, such as Discord embeds and email.
+package raster
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/HugoSmits86/nativewebp"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	// Decoders of thumbnails
+	_ "image/gif"
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/webp"
+)
+
+type Format string
+
+const (
+	PNG  Format = "png"
+	WebP Format = "webp"
+)
+
+var ErrUnknownFormat = errors.New("unknown image format")
+
+// ParseFormat parses the name of a format, empty is PNG.
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case "", PNG:
+		return PNG, nil
+	case WebP:
+		return WebP, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownFormat, s)
+}
+
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+// Encode writes img in the format, WebP is lossless.
+func Encode(w io.Writer, img image.Image, f Format) error {
+	switch f {
+	case PNG:
+		return png.Encode(w, img)
+	case WebP:
+		return nativewebp.Encode(w, img, nil)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownFormat, f)
+}
+
+var (
+	regularFont = sync.OnceValue(func() *opentype.Font {
+		return mustParseFont(goregular.TTF)
+	})
+	monoFont = sync.OnceValue(func() *opentype.Font {
+		return mustParseFont(gomono.TTF)
+	})
+)
+
+func mustParseFont(ttf []byte) *opentype.Font {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Face returns a face of the Go font of size in pixels. Faces are
+// not safe for concurrent use so callers get a face of their own.
+func Face(size float64, mono bool) font.Face {
+	f := regularFont()
+	if mono {
+		f = monoFont()
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return face
+}
+
+// ParseHexColor parses colors of the form #rgb, #rrggbb or #rrggbbaa.
+func ParseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// MustParseHexColor is ParseHexColor for colors known to be valid.
+func MustParseHexColor(s string) color.NRGBA {
+	c, err := ParseHexColor(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// FillRect blends c over r of dst.
+func FillRect(dst *image.RGBA, r image.Rectangle, c color.Color) {
+	xdraw.Draw(dst, r, image.NewUniform(c), image.Point{}, xdraw.Over)
+}
+
+// DrawCover scales src to cover r of dst, cropping the overflow
+// around its center like CSS object-fit: cover.
+func DrawCover(dst *image.RGBA, r image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	if sb.Empty() || r.Empty() {
+		return
+	}
+
+	// Crop src to the aspect ratio of r
+	crop := sb
+	if sb.Dx()*r.Dy() > sb.Dy()*r.Dx() {
+		w := sb.Dy() * r.Dx() / r.Dy()
+		crop.Min.X += (sb.Dx() - w) / 2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := sb.Dx() * r.Dy() / r.Dx()
+		crop.Min.Y += (sb.Dy() - h) / 2
+		crop.Max.Y = crop.Min.Y + h
+	}
+	xdraw.CatmullRom.Scale(dst, r, src, crop, xdraw.Over, nil)
+}
+
+// DrawText draws s with its baseline starting at x, y.
+func DrawText(dst *image.RGBA, face font.Face, x, y int, c color.Color, s string) {
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+// TextWidth is the width of s in pixels when drawn with face.
+func TextWidth(face font.Face, s string) int {
+	return font.MeasureString(face, s).Ceil()
+}
+
+// Truncate shortens s with an ellipsis to fit within width pixels.
+func Truncate(face font.Face, s string, width int) string {
+	if TextWidth(face, s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		t := strings.TrimRight(string(runes), " ") + "…"
+		if TextWidth(face, t) <= width {
+			return t
+		}
+	}
+	return ""
+}
+
+// TruncateRunes shortens s with an ellipsis to at most n runes.
+func TruncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,96 @@
+package raster
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, PNG, f)
+
+	f, err = ParseFormat("WebP")
+	require.NoError(t, err)
+	assert.Equal(t, WebP, f)
+	assert.Equal(t, "image/webp", f.ContentType())
+
+	_, err = ParseFormat("gif")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestParseHexColor(t *testing.T) {
+	c, err := ParseHexColor("#ff0033")
+	require.NoError(t, err)
+	assert.Equal(t, color.NRGBA{R: 0xff, G: 0x00, B: 0x33, A: 0xff}, c)
+
+	c, err = ParseHexColor("#00000099")
+	require.NoError(t, err)
+	assert.Equal(t, color.NRGBA{A: 0x99}, c)
+
+	c, err = ParseHexColor("#fff")
+	require.NoError(t, err)
+	assert.Equal(t, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, c)
+
+	_, err = ParseHexColor("#ggg")
+	assert.Error(t, err)
+	_, err = ParseHexColor("#12345")
+	assert.Error(t, err)
+}
+
+func TestEncode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	FillRect(img, image.Rect(0, 0, 2, 2), MustParseHexColor("#ff0033"))
+
+	for _, f := range []Format{PNG, WebP} {
+		t.Run(string(f), func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Encode(&buf, img, f))
+
+			decoded, name, err := image.Decode(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, string(f), name)
+			assert.Equal(t, img.Bounds(), decoded.Bounds())
+			r, g, b, _ := decoded.At(0, 0).RGBA()
+			assert.Equal(t, [3]uint32{0xffff, 0, 0x3333}, [3]uint32{r, g, b})
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	face := Face(12, false)
+	defer face.Close()
+
+	assert.Equal(t, "short", Truncate(face, "short", 100))
+
+	long := "a rather long title that will not fit"
+	truncated := Truncate(face, long, 60)
+	assert.NotEqual(t, long, truncated)
+	assert.LessOrEqual(t, TextWidth(face, truncated), 60)
+	assert.Contains(t, truncated, "…")
+}
+
+func TestDrawCover(t *testing.T) {
+	// A wide source whose center half is red
+	src := image.NewRGBA(image.Rect(0, 0, 40, 10))
+	FillRect(src, src.Bounds(), color.White)
+	FillRect(src, image.Rect(10, 0, 30, 10), MustParseHexColor("#ff0000"))
+
+	dst := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	DrawCover(dst, dst.Bounds(), src)
+	// Cropped to the center so the sides are cut off
+	r, g, _, _ := dst.At(0, 5).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+	assert.Less(t, g, uint32(0x1000))
+}
+
+func TestTruncateRunes(t *testing.T) {
+	assert.Equal(t, "short", TruncateRunes("short", 5))
+	assert.Equal(t, "shor…", TruncateRunes("shorter", 5))
+	assert.Equal(t, "日本…", TruncateRunes("日本語です", 3))
+}
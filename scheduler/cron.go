@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// Whether the day fields were restricted, if both are
+	// a day matches when either does as in standard cron.
+	domRestricted, dowRestricted bool
+}
+
+type cronField struct {
+	name     string
+	min, max uint
+}
+
+var cronFields = [5]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a standard five field cron expression of minute,
+// hour, day of month, month and day of week. Fields are either * or
+// comma separated values and ranges, optionally with a step such as
+// */15 or 1-5/2. Sunday is both 0 and 7. The descriptors @yearly,
+// @monthly, @weekly, @daily and @hourly are supported as well.
+func ParseCron(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := cronDescriptors[spec]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return Schedule{}, fmt.Errorf("expected %d fields but got %d", len(cronFields), len(fields))
+	}
+
+	var (
+		sched Schedule
+		sets  = [5]*uint64{&sched.minute, &sched.hour, &sched.dom, &sched.month, &sched.dow}
+	)
+	for i, f := range fields {
+		set, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return Schedule{}, fmt.Errorf("%s: %s", cronFields[i].name, err)
+		}
+		*sets[i] = set
+	}
+
+	// Sunday as 7 is the same as 0
+	if sched.dow&(1<<7) != 0 {
+		sched.dow |= 1
+		sched.dow &^= 1 << 7
+	}
+	sched.domRestricted = fields[2] != "*"
+	sched.dowRestricted = fields[4] != "*"
+
+	return sched, nil
+}
+
+func parseCronField(field string, f cronField) (uint64, error) {
+	var set uint64
+	for item := range strings.SplitSeq(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+
+		step := uint64(1)
+		if hasStep {
+			s, err := strconv.ParseUint(stepStr, 10, 8)
+			if err != nil || s == 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = s
+		}
+
+		var lo, hi uint64
+		switch {
+		case rng == "*":
+			lo, hi = uint64(f.min), uint64(f.max)
+		case strings.Contains(rng, "-"):
+			loStr, hiStr, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseCronValue(loStr, f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseCronValue(hiStr, f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			v, err := parseCronValue(rng, f)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			// a/n runs from a through the maximum
+			if hasStep {
+				hi = uint64(f.max)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func parseCronValue(s string, f cronField) (uint64, error) {
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || v < uint64(f.min) || v > uint64(f.max) {
+		return 0, fmt.Errorf("invalid value %q, expected %d-%d", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (s Schedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Next returns the first time after t matching the schedule, in the
+// location of t. It returns the zero time if there is none within
+// the next five years, such as for the 30th of February.
+func (s Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	limit := t.AddDate(5, 0, 0)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			// Skip straight to the next matching minute of the hour
+			next := bits.TrailingZeros64(s.minute >> uint(t.Minute()))
+			if next == 64 {
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			} else {
+				t = t.Add(time.Duration(next) * time.Minute)
+			}
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
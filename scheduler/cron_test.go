@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2025, time.March, 12, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2025, time.March, 12, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, time.March, 12, 10, 30, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2025, time.March, 13, 9, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, time.March, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1", time.Date(2025, time.March, 17, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2025, time.March, 16, 9, 0, 0, 0, time.UTC)},
+		{"30 8-18/2 * * 1-5", time.Date(2025, time.March, 12, 10, 30, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 20 * 5", time.Date(2025, time.March, 14, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			sched, err := ParseCron(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sched.Next(from))
+		})
+	}
+
+	impossible, err := ParseCron("0 0 30 2 *")
+	require.NoError(t, err)
+	assert.True(t, impossible.Next(from).IsZero())
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "@often"} {
+		_, err := ParseCron(spec)
+		assert.Error(t, err, spec)
+	}
+}
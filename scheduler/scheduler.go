@@ -0,0 +1,189 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/tifye/shigure/assert"
+)
+
+const jobTimeout = 5 * time.Minute
+
+var ErrJobNotFound = errors.New("job not found")
+
+type Job func(ctx context.Context) error
+
+type job struct {
+	name     string
+	spec     string
+	schedule Schedule
+	run      Job
+	next     time.Time
+	// mu prevents a scheduled and a triggered
+	// run from overlapping.
+	mu sync.Mutex
+}
+
+type JobInfo struct {
+	Name string    `json:"name"`
+	Spec string    `json:"spec"`
+	Next time.Time `json:"next,omitzero"`
+}
+
+// Scheduler runs jobs on cron schedules.
+type Scheduler struct {
+	logger   *log.Logger
+	location *time.Location
+
+	jobs []*job
+	mu   sync.Mutex
+	// wake interrupts the wait for the next job when
+	// jobs are added while running.
+	wake chan struct{}
+}
+
+// New creates a scheduler evaluating cron schedules in loc.
+func New(logger *log.Logger, loc *time.Location) *Scheduler {
+	assert.AssertNotNil(logger)
+	assert.AssertNotNil(loc)
+	return &Scheduler{
+		logger:   logger,
+		location: loc,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Add schedules run under name according to the cron
+// expression spec, see ParseCron.
+func (s *Scheduler) Add(name string, spec string, run Job) error {
+	assert.AssertNotEmpty(name)
+	assert.AssertNotNil(run)
+
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return fmt.Errorf("parse %q: %s", spec, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.jobs, func(j *job) bool { return j.name == name }) {
+		return fmt.Errorf("job %s already exists", name)
+	}
+	s.jobs = append(s.jobs, &job{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		run:      run,
+		next:     schedule.Next(time.Now().In(s.location)),
+	})
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]JobInfo, len(s.jobs))
+	for i, j := range s.jobs {
+		infos[i] = JobInfo{Name: j.name, Spec: j.spec, Next: j.next}
+	}
+	slices.SortFunc(infos, func(a, b JobInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return infos
+}
+
+// Trigger runs the job name right away, outside of its schedule.
+func (s *Scheduler) Trigger(ctx context.Context, name string) error {
+	s.mu.Lock()
+	idx := slices.IndexFunc(s.jobs, func(j *job) bool { return j.name == name })
+	if idx < 0 {
+		s.mu.Unlock()
+		return ErrJobNotFound
+	}
+	j := s.jobs[idx]
+	s.mu.Unlock()
+
+	s.logger.Info("triggered job", "name", name)
+	return s.runJob(ctx, j)
+}
+
+func (s *Scheduler) runJob(ctx context.Context, j *job) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+	return j.run(ctx)
+}
+
+// Run runs jobs as they are due until ctx is done. Jobs run
+// one at a time, a job that is due while another one runs is
+// run afterwards.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		s.mu.Lock()
+		var next time.Time
+		for _, j := range s.jobs {
+			if !j.next.IsZero() && (next.IsZero() || j.next.Before(next)) {
+				next = j.next
+			}
+		}
+		s.mu.Unlock()
+
+		// Without any jobs there is only the wake to wait for
+		var (
+			timer   *time.Timer
+			timerCh <-chan time.Time
+		)
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			timerCh = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-timerCh:
+			s.runDue(ctx, time.Now().In(s.location))
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	var due []*job
+	for _, j := range s.jobs {
+		if !j.next.IsZero() && !j.next.After(now) {
+			due = append(due, j)
+			j.next = j.schedule.Next(now)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, j := range due {
+		s.logger.Info("running job", "name", j.name)
+		if err := s.runJob(ctx, j); err != nil {
+			s.logger.Error("run job", "name", j.name, "err", err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerTrigger(t *testing.T) {
+	s := New(log.New(io.Discard), time.UTC)
+
+	runs := 0
+	require.NoError(t, s.Add("digest", "0 9 * * *", func(ctx context.Context) error {
+		runs++
+		return errors.New("webhook down")
+	}))
+	assert.Error(t, s.Add("digest", "@daily", func(ctx context.Context) error { return nil }))
+
+	assert.EqualError(t, s.Trigger(context.Background(), "digest"), "webhook down")
+	assert.Equal(t, 1, runs)
+	assert.ErrorIs(t, s.Trigger(context.Background(), "nope"), ErrJobNotFound)
+
+	jobs := s.Jobs()
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, 9, jobs[0].Next.Hour())
+	}
+}
@@ -7,18 +7,31 @@ import (
 	_ "github.com/marcboeker/go-duckdb/v2"
 )
 
-//go:embed schema/codeActivity.sql
-var codeActivitySchema []byte
+var (
+	//go:embed schema/codeActivity.sql
+	codeActivitySchema []byte
+	//go:embed schema/youtubeActivity.sql
+	youtubeActivitySchema []byte
+)
+
+const DefaultDuckDBPath = "./data/analytics.db"
 
 type DuckDB = *sqlx.DB
 
 func InitDuckDB() (DuckDB, error) {
-	db, err := sqlx.Connect("duckdb", "./data/analytics.db")
+	return OpenDuckDB(DefaultDuckDBPath)
+}
+
+// OpenDuckDB connects to the database at path and applies
+// the schema. An empty path opens an in-memory database.
+func OpenDuckDB(path string) (DuckDB, error) {
+	db, err := sqlx.Connect("duckdb", path)
 	if err != nil {
 		return nil, err
 	}
 
 	_ = db.MustExec(string(codeActivitySchema))
+	_ = db.MustExec(string(youtubeActivitySchema))
 
 	return db, nil
 }
@@ -39,4 +39,12 @@ create or replace view sessions as (
     from sessioned
     group by id
     order by "start" desc
-);
\ No newline at end of file
+);
+alter table code_activity add column if not exists excluded boolean default false;
+alter table code_activity add column if not exists editor varchar default 'vscode';
+alter table code_activity add column if not exists username varchar default 'owner';
+create table if not exists code_activity_migrations (
+    "name" varchar primary key,
+    "version" integer not null,
+    applied_at timestamp
+);
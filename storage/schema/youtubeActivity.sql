@@ -0,0 +1,10 @@
+create table if not exists youtube_activity (
+    video_id varchar check (length(video_id) < 32),
+    title varchar,
+    channel varchar,
+    duration_seconds integer check (duration_seconds >= 0),
+    started_at timestamp,
+    ended_at timestamp
+);
+alter table youtube_activity add column if not exists provider varchar default 'youtube';
+alter table youtube_activity add column if not exists url varchar;